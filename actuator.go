@@ -3,9 +3,11 @@ package eureka
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 type status struct {
@@ -35,26 +37,47 @@ type routeInfo struct {
 
 type handlerFunc func(client *Client) interface{}
 
+// 相对于 management.basePath(默认 /actuator) 的路径
 var routePath = []routeInfo{
 	//处理eureka的心跳等
-	{path: "^/actuator$", function: actuatorLinks},
-	{path: "^/actuator/info$", function: actuatorInfo},
-	{path: "^/actuator/health$", function: actuatorHealth},
-	{path: "^/actuator\\w*", function: actuatorAny},
+	{path: "^/?$", function: actuatorLinks},
+	{path: "^/info$", function: actuatorInfo},
+	{path: "^/health$", function: actuatorHealth},
+	{path: "^/metrics$", function: actuatorMetrics},
+	{path: "^/probes$", function: actuatorProbes},
+	{path: "^/", function: actuatorAny},
 }
 
 func (client *Client) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	for _, route := range routePath {
-		ok, _ := regexp.Match(route.path, []byte(r.URL.Path))
-		if ok {
-			client.writeJson(w, r, route.function(client), true)
-			return
+	basePath := client.config.Load().InstanceConfig.Management.GetBasePath()
+	if strings.HasPrefix(r.URL.Path, basePath) {
+		path := strings.TrimPrefix(r.URL.Path, basePath)
+		for _, route := range routePath {
+			ok, _ := regexp.MatchString(route.path, path)
+			if ok {
+				client.writeJson(w, r, route.function(client), true)
+				return
+			}
 		}
 	}
 
 	client.writeJson(w, r, "404 not found", false)
 }
 
+// 配置了独立的管理端口时, 在该端口启动 actuator 服务
+func (client *Client) serveManagement() {
+	port := client.config.Load().InstanceConfig.Management.Port
+	if 0 >= port || port == client.GetPort() {
+		return
+	}
+
+	client.logger.Info(fmt.Sprintf("Start actuator server on management port %d", port))
+	err := http.ListenAndServe(net.JoinHostPort("", strconv.Itoa(port)), client)
+	if nil != err {
+		client.logger.Error(fmt.Sprintf("Actuator server on management port %d stopped, err=%s", port, err.Error()))
+	}
+}
+
 func (client *Client) writeJson(rw http.ResponseWriter, req *http.Request, response interface{}, isJson bool) {
 	origin := req.Header.Get("origin")
 	rw.Header().Set("cache-control", "No-Cache")
@@ -98,17 +121,30 @@ func (client *Client) toStringByte(v interface{}) ([]byte, error) {
 
 func actuatorLinks(client *Client) interface{} {
 	links := make(map[string]href, 10)
-	url := fmt.Sprintf("http://%s:%d", client.instance.IpAddr, client.config.InstanceConfig.NonSecurePort)
+	management := client.config.Load().InstanceConfig.Management
+	port := client.config.Load().InstanceConfig.NonSecurePort
+	if 0 < management.Port {
+		port = management.Port
+	}
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(client.instance.Load().IpAddr, strconv.Itoa(port)), management.GetBasePath())
 	links["self"] = href{
-		Href:      url + "/actuator",
+		Href:      url,
 		Templated: false,
 	}
 	links["info"] = href{
-		Href:      url + "/actuator/info",
+		Href:      url + "/info",
 		Templated: false,
 	}
 	links["health"] = href{
-		Href:      url + "/actuator/health",
+		Href:      url + "/health",
+		Templated: false,
+	}
+	links["metrics"] = href{
+		Href:      url + "/metrics",
+		Templated: false,
+	}
+	links["probes"] = href{
+		Href:      url + "/probes",
 		Templated: false,
 	}
 
@@ -119,7 +155,7 @@ func actuatorLinks(client *Client) interface{} {
 
 func actuatorInfo(client *Client) interface{} {
 	appStatus := status{}
-	appStatus.Name = client.config.InstanceConfig.AppName
+	appStatus.Name = client.config.Load().InstanceConfig.AppName
 	appStatus.Server.Port = strconv.Itoa(client.GetPort())
 	return appStatus
 }
@@ -131,6 +167,18 @@ func actuatorHealth(_ *Client) interface{} {
 	return appHealth
 }
 
+func actuatorMetrics(client *Client) interface{} {
+	metrics := make(map[string]interface{}, 3)
+	metrics["registry.cache"] = client.CacheStats()
+	metrics["bulkhead"] = client.BulkheadStats()
+	metrics["hedge"] = client.HedgeStats()
+	return metrics
+}
+
+func actuatorProbes(client *Client) interface{} {
+	return client.ProbeStates()
+}
+
 func actuatorAny(_ *Client) interface{} {
 	return new(interface{})
 }
@@ -0,0 +1,102 @@
+package eureka
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+)
+
+func serveActuator(t *testing.T, client *Client, path string) (int, map[string]interface{}) {
+	recorder := httptest.NewRecorder()
+	client.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]interface{}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &body)
+	return recorder.Code, body
+}
+
+// 按 management.basePath 路由 actuator 端点, 链接使用管理端口
+func TestActuatorRoutesUnderBasePath(t *testing.T) {
+	stub := newEurekaStub(t)
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.InstanceConfig.AppName = "self"
+		cfg.InstanceConfig.NonSecurePort = 8080
+		cfg.InstanceConfig.Management.Port = 9090
+		cfg.InstanceConfig.Management.BasePath = "/manage"
+	})
+
+	if _, body := serveActuator(t, client, "/manage/health"); "UP" != body["status"] {
+		t.Fatalf("got health %v", body)
+	}
+	if _, body := serveActuator(t, client, "/manage/info"); "self" != body["name"] {
+		t.Fatalf("got info %v", body)
+	}
+	_, body := serveActuator(t, client, "/manage")
+	links, _ := body["_links"].(map[string]interface{})
+	health, _ := links["health"].(map[string]interface{})
+	if "http://127.0.0.1:9090/manage/health" != health["href"] {
+		t.Fatalf("got links %v", links)
+	}
+	if _, body := serveActuator(t, client, "/actuator/health"); nil != body {
+		t.Fatalf("got %v outside of the base path, want not found", body)
+	}
+}
+
+// 配置了独立的管理端口时在该端口启动 actuator 服务
+func TestServeManagementPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	stub := newEurekaStub(t)
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.InstanceConfig.Management.Port = port
+	})
+	go client.serveManagement()
+
+	url := "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(port)) + "/actuator/health"
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := http.Get(url)
+		if nil == err {
+			var body map[string]interface{}
+			_ = json.NewDecoder(resp.Body).Decode(&body)
+			_ = resp.Body.Close()
+			if "UP" != body["status"] {
+				t.Fatalf("got health %v", body)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("actuator server not started on management port: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 管理端口与服务端口相同时不启动 actuator 服务
+func TestServeManagementSamePort(t *testing.T) {
+	stub := newEurekaStub(t)
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.InstanceConfig.Management.Port = 8080
+	})
+
+	done := make(chan struct{})
+	go func() {
+		client.serveManagement()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("serveManagement should return when management port equals the service port")
+	}
+}
@@ -0,0 +1,143 @@
+package eureka
+
+import (
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+	"math/rand"
+	"time"
+)
+
+// 负载均衡策略
+type loadBalancer interface {
+	// 从候选实例中选出一个, candidates 不为空且只读
+	choose(candidates []*server) *server
+}
+
+// 根据配置的名称创建负载均衡策略
+func newLoadBalancer(name string, client *Client) (loadBalancer, error) {
+	switch name {
+	case config.LoadBalancerRoundRobin:
+		return &roundRobinBalancer{client: client}, nil
+	case config.LoadBalancerRandom:
+		return &randomBalancer{client: client}, nil
+	case config.LoadBalancerPeakEwma:
+		return &peakEwmaBalancer{client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown load balancer: %s", name)
+	}
+}
+
+// 轮询, 存在预热中的实例时按预热权重随机
+type roundRobinBalancer struct {
+	client *Client
+}
+
+func (balancer *roundRobinBalancer) choose(candidates []*server) *server {
+	if s := balancer.client.chooseWarming(candidates); nil != s {
+		return s
+	}
+	index := balancer.client.autoIncr.Inc() % int64(len(candidates))
+	return candidates[index]
+}
+
+// 随机, 存在预热中的实例时按预热权重随机
+type randomBalancer struct {
+	client *Client
+}
+
+func (balancer *randomBalancer) choose(candidates []*server) *server {
+	if s := balancer.client.chooseWarming(candidates); nil != s {
+		return s
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// 按应用的调用策略从有效实例中选出一个可用且未尝试过的实例, 没有符合条件的实例时返回nil
+func (client *Client) selectServer(policy *appPolicy, servers []*server, tried []*core.Instance) *server {
+	candidates := client.candidates(policy, servers, tried)
+	if 0 == len(candidates) {
+		return nil
+	}
+	return policy.balancer.choose(candidates)
+}
+
+// 过滤出元数据匹配、可用且未尝试过的实例, 开启同可用区优先时只保留同可用区的实例(如果有)
+// 可用实例全部尝试过或均不可用时, 不再过滤不可用的实例, 避免应用完全不可用
+func (client *Client) candidates(policy *appPolicy, servers []*server, tried []*core.Instance) []*server {
+	now := time.Now().UnixNano()
+
+	//大多数情况下所有实例均可用, 直接使用原列表避免分配
+	if !policy.filtering() && client.allCandidates(servers, tried, now) {
+		return servers
+	}
+
+	matched := servers
+	if 0 < len(policy.config.MetadataFilters) {
+		matched = make([]*server, 0, len(servers))
+		for _, s := range servers {
+			if policy.matches(s.instance) {
+				matched = append(matched, s)
+			}
+		}
+	}
+
+	candidates := make([]*server, 0, len(matched))
+	for _, s := range matched {
+		if !isTried(s.instance, tried) && client.isAvailable(s.instance, now) {
+			candidates = append(candidates, s)
+		}
+	}
+	if 0 == len(candidates) {
+		for _, s := range matched {
+			if !isTried(s.instance, tried) {
+				candidates = append(candidates, s)
+			}
+		}
+	}
+
+	if zone := client.zone(); policy.config.PreferSameZone && 0 < len(zone) {
+		sameZone := make([]*server, 0, len(candidates))
+		for _, s := range candidates {
+			if zone == metadataString(s.instance, metadataZoneKey) {
+				sameZone = append(sameZone, s)
+			}
+		}
+		if 0 < len(sameZone) {
+			return sameZone
+		}
+	}
+	return candidates
+}
+
+// 当前实例所在的可用区
+func (client *Client) zone() string {
+	instance := client.instance.Load()
+	if nil == instance {
+		return ""
+	}
+	return metadataString(instance, metadataZoneKey)
+}
+
+func (client *Client) allCandidates(servers []*server, tried []*core.Instance, now int64) bool {
+	//没有被摘除或不健康的实例时无需逐个检查
+	checkAvailable := client.mayBeUnavailable(now)
+	if !checkAvailable && 0 == len(tried) {
+		return true
+	}
+	for _, s := range servers {
+		if isTried(s.instance, tried) || (checkAvailable && !client.isAvailable(s.instance, now)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTried(instance *core.Instance, tried []*core.Instance) bool {
+	for _, t := range tried {
+		if nil != t && t.InstanceId == instance.InstanceId {
+			return true
+		}
+	}
+	return false
+}
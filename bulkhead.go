@@ -0,0 +1,193 @@
+package eureka
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/config"
+	"go.uber.org/atomic"
+	"sync"
+	"time"
+)
+
+const (
+	// 超过并发请求数
+	RejectReasonConcurrency = "concurrency"
+	// 超过每秒请求数
+	RejectReasonRateLimit = "rateLimit"
+)
+
+// ErrRejected 请求被舱壁或限流拒绝
+var ErrRejected = errors.New("request rejected")
+
+// RejectedError 请求被舱壁或限流拒绝, 未发送到任何实例, 可用 errors.Is(err, ErrRejected) 判断
+type RejectedError struct {
+	AppId string
+	// 拒绝原因: RejectReasonConcurrency、RejectReasonRateLimit
+	Reason string
+}
+
+func (err *RejectedError) Error() string {
+	return fmt.Sprintf("Request to %s rejected, reason=%s", err.AppId, err.Reason)
+}
+
+func (err *RejectedError) Unwrap() error {
+	return ErrRejected
+}
+
+// BulkheadStats 下游应用的舱壁和限流统计
+type BulkheadStats struct {
+	// 进行中的请求数
+	Inflight int64 `json:"inflight"`
+	// 放行的请求数
+	Accepted int64 `json:"accepted"`
+	// 因并发请求数超限拒绝的请求数
+	RejectedConcurrency int64 `json:"rejectedConcurrency"`
+	// 因每秒请求数超限拒绝的请求数
+	RejectedRateLimit int64 `json:"rejectedRateLimit"`
+	// 因实例并发请求数超限换实例的次数
+	InstanceFull int64 `json:"instanceFull"`
+}
+
+// 下游应用的舱壁和限流
+type bulkhead struct {
+	maxConcurrent            int64
+	maxConcurrentPerInstance int64
+	// 为nil时不限流
+	rateLimiter *tokenBucket
+
+	inflight            atomic.Int64
+	accepted            atomic.Int64
+	rejectedConcurrency atomic.Int64
+	rejectedRateLimit   atomic.Int64
+	instanceFull        atomic.Int64
+
+	mutex sync.Mutex
+	// 各实例进行中的请求数
+	// key: instanceId
+	instanceInflight map[string]int64
+}
+
+// 未配置任何限制时返回nil
+func newBulkhead(policy *config.ClientPolicy) *bulkhead {
+	if 0 >= policy.MaxConcurrentRequests && 0 >= policy.MaxConcurrentRequestsPerInstance && 0 >= policy.RateLimitPerSecond {
+		return nil
+	}
+
+	head := &bulkhead{
+		maxConcurrent:            int64(policy.MaxConcurrentRequests),
+		maxConcurrentPerInstance: int64(policy.MaxConcurrentRequestsPerInstance),
+		instanceInflight:         make(map[string]int64),
+	}
+	if 0 < policy.RateLimitPerSecond {
+		head.rateLimiter = newTokenBucket(policy.RateLimitPerSecond, policy.GetRateLimitBurst())
+	}
+	return head
+}
+
+// 申请一个应用级的请求名额, 成功后须调用 release
+func (head *bulkhead) acquire(appId string) error {
+	if nil != head.rateLimiter && !head.rateLimiter.allow(time.Now()) {
+		head.rejectedRateLimit.Inc()
+		return &RejectedError{AppId: appId, Reason: RejectReasonRateLimit}
+	}
+
+	inflight := head.inflight.Inc()
+	if 0 < head.maxConcurrent && inflight > head.maxConcurrent {
+		head.inflight.Dec()
+		head.rejectedConcurrency.Inc()
+		return &RejectedError{AppId: appId, Reason: RejectReasonConcurrency}
+	}
+
+	head.accepted.Inc()
+	return nil
+}
+
+func (head *bulkhead) release() {
+	head.inflight.Dec()
+}
+
+// 申请一个实例级的请求名额, 成功后须调用 releaseInstance
+func (head *bulkhead) acquireInstance(instanceId string) bool {
+	if 0 >= head.maxConcurrentPerInstance {
+		return true
+	}
+
+	head.mutex.Lock()
+	defer head.mutex.Unlock()
+	if head.instanceInflight[instanceId] >= head.maxConcurrentPerInstance {
+		head.instanceFull.Inc()
+		return false
+	}
+	head.instanceInflight[instanceId]++
+	return true
+}
+
+func (head *bulkhead) releaseInstance(instanceId string) {
+	if 0 >= head.maxConcurrentPerInstance {
+		return
+	}
+
+	head.mutex.Lock()
+	defer head.mutex.Unlock()
+	head.instanceInflight[instanceId]--
+	if 0 >= head.instanceInflight[instanceId] {
+		delete(head.instanceInflight, instanceId)
+	}
+}
+
+func (head *bulkhead) stats() BulkheadStats {
+	return BulkheadStats{
+		Inflight:            head.inflight.Load(),
+		Accepted:            head.accepted.Load(),
+		RejectedConcurrency: head.rejectedConcurrency.Load(),
+		RejectedRateLimit:   head.rejectedRateLimit.Load(),
+		InstanceFull:        head.instanceFull.Load(),
+	}
+}
+
+// 令牌桶
+type tokenBucket struct {
+	mutex sync.Mutex
+	// 每秒生成的令牌数
+	rate  float64
+	burst float64
+	// 当前令牌数
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// 取一个令牌, 没有令牌时返回false
+func (bucket *tokenBucket) allow(now time.Time) bool {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+
+	if elapsed := now.Sub(bucket.last); 0 < elapsed {
+		bucket.tokens += elapsed.Seconds() * bucket.rate
+		if bucket.tokens > bucket.burst {
+			bucket.tokens = bucket.burst
+		}
+		bucket.last = now
+	}
+
+	if 1 > bucket.tokens {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// 获取各下游应用的舱壁和限流统计, 只包含配置了限制的应用
+// key: appId
+func (client *Client) BulkheadStats() map[string]BulkheadStats {
+	stats := make(map[string]BulkheadStats, len(client.policies))
+	for appId, policy := range client.policies {
+		if nil != policy.bulkhead {
+			stats[appId] = policy.bulkhead.stats()
+		}
+	}
+	return stats
+}
@@ -0,0 +1,152 @@
+package eureka
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+)
+
+// 阻塞到 release 关闭的后端
+func newBlockingBackend(t *testing.T) (*httptest.Server, chan struct{}, *sync.WaitGroup) {
+	release := make(chan struct{})
+	var arrived sync.WaitGroup
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived.Done()
+		<-release
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server, release, &arrived
+}
+
+// 超过应用并发请求数时立即拒绝, 实例并发请求数已满时换一个实例
+func TestBulkheadRejectsOverConcurrency(t *testing.T) {
+	server, release, arrived := newBlockingBackend(t)
+	stub := newEurekaStub(t, testApp("DEMO", serverInstance(t, "DEMO", "demo-1", server), serverInstance(t, "DEMO", "demo-2", server)))
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.ClientConfig.LoadBalancer = config.LoadBalancerPeakEwma
+		cfg.Clients = map[string]config.ClientPolicy{"demo": {MaxConcurrentRequests: 2, MaxConcurrentRequestsPerInstance: 1}}
+	})
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	httpClient := &http.Client{Transport: client.NewTransport(nil)}
+
+	var wg sync.WaitGroup
+	arrived.Add(2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := httpClient.Get("http://demo/ping")
+			if nil != err {
+				t.Error(err)
+				return
+			}
+			_, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		}()
+	}
+	//两个请求分别落在两个实例上
+	arrived.Wait()
+
+	var rejected *RejectedError
+	if _, err := httpClient.Get("http://demo/ping"); !errors.As(err, &rejected) || RejectReasonConcurrency != rejected.Reason {
+		t.Fatalf("got %v, want concurrency rejection", err)
+	}
+	close(release)
+	wg.Wait()
+
+	stats := client.BulkheadStats()["DEMO"]
+	if 0 != stats.Inflight || 2 != stats.Accepted || 1 != stats.RejectedConcurrency {
+		t.Fatalf("got %+v", stats)
+	}
+}
+
+// 实例并发请求数已满而跳过的实例不计入进行中请求数
+func TestBulkheadFullInstancesReleasePending(t *testing.T) {
+	server, release, arrived := newBlockingBackend(t)
+	stub := newEurekaStub(t, testApp("DEMO", serverInstance(t, "DEMO", "demo-1", server)))
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.ClientConfig.LoadBalancer = config.LoadBalancerPeakEwma
+		cfg.Clients = map[string]config.ClientPolicy{"demo": {MaxConcurrentRequestsPerInstance: 1}}
+	})
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	httpClient := &http.Client{Transport: client.NewTransport(nil)}
+
+	done := make(chan struct{})
+	arrived.Add(1)
+	go func() {
+		defer close(done)
+		resp, err := httpClient.Get("http://demo/ping")
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		_ = resp.Body.Close()
+	}()
+	arrived.Wait()
+
+	for i := 0; i < 3; i++ {
+		var rejected *RejectedError
+		if _, err := httpClient.Get("http://demo/ping"); !errors.As(err, &rejected) || RejectReasonConcurrency != rejected.Reason {
+			t.Fatalf("got %v, want concurrency rejection", err)
+		}
+	}
+	close(release)
+	<-done
+
+	if pending := pendingOf(client, "demo-1"); 0 != pending {
+		t.Fatalf("got %d pending requests, want 0", pending)
+	}
+	if stats := client.BulkheadStats()["DEMO"]; 3 != stats.InstanceFull {
+		t.Fatalf("got %+v, want 3 instanceFull", stats)
+	}
+}
+
+// 超过每秒请求数时立即拒绝
+func TestBulkheadRateLimit(t *testing.T) {
+	backend := newTestBackend(t, http.StatusOK, 0)
+	client := newPolicyTestClient(t, config.ClientPolicy{RateLimitPerSecond: 1},
+		serverInstance(t, "DEMO", "demo-1", backend.Server))
+
+	if status, _ := doGet(t, client, "http://demo/ping"); http.StatusOK != status {
+		t.Fatalf("got status %d", status)
+	}
+	_, err := (&http.Client{Transport: client.NewTransport(nil)}).Get("http://demo/ping")
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || RejectReasonRateLimit != rejected.Reason || !errors.Is(err, ErrRejected) {
+		t.Fatalf("got %v, want rate limit rejection", err)
+	}
+	if 1 != backend.requests.Load() {
+		t.Fatalf("rejected request reached the backend")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := &tokenBucket{rate: 10, burst: 2, tokens: 2, last: now}
+	if !bucket.allow(now) || !bucket.allow(now) || bucket.allow(now) {
+		t.Fatal("burst not honored")
+	}
+	if !bucket.allow(now.Add(100 * time.Millisecond)) {
+		t.Fatal("token not refilled after 100ms at 10/s")
+	}
+	if bucket.allow(now.Add(100 * time.Millisecond)) {
+		t.Fatal("more tokens than refilled")
+	}
+}
+
+func TestNewBulkheadNilWithoutLimits(t *testing.T) {
+	if nil != newBulkhead(&config.ClientPolicy{}) {
+		t.Fatal("bulkhead created without limits")
+	}
+}
@@ -21,13 +21,15 @@ const (
 	//
 	httpKey  = 0
 	httpsKey = 1
+	// 不区分协议
+	anyKey = -1
 )
 
 type Client struct {
 	Running bool
 
-	//
-	apiClient *core.EurekaServerApi
+	//配置热更新时替换
+	apiClient atomic.Pointer[core.EurekaServerApi]
 
 	//自增器
 	autoIncr *atomic.Int64
@@ -40,31 +42,41 @@ type Client struct {
 
 	mutex sync.RWMutex
 
-	config *config.Config
+	//配置文件路径, 配置热更新时重新加载
+	configPath string
+	//配置热更新时替换, 只读
+	config atomic.Pointer[config.Config]
 
 	// current client (instance) config
-	instance *core.Instance
+	// 修改时复制后整体替换, 见 updateInstance, 读取无需加锁
+	instance atomic.Pointer[core.Instance]
 
-	// applications registry
-	// key: appId
-	// value: Application
-	registryAppMap map[string]*core.Application
+	// applications registry snapshot, see registry
+	snapshot atomic.Pointer[registry]
 
-	// instances registry
-	// key: appId
-	// value:
-	//		key:  int(0...n)
-	//		value: InstanceConfig
-	activeInstanceMap map[string]map[int]*core.Instance
+	// 串行化注册表快照的写操作
+	registryMutex sync.Mutex
+
+	// 本地缓存未命中时的按需查询
+	loader *appLoader
+
+	// 故障实例摘除
+	outliers *outlierDetector
+
+	// 主动健康检查
+	prober *healthProber
+
+	// 负载均衡策略
+	balancer loadBalancer
 
-	// instance real url map
+	// 下游应用的调用策略
 	// key: appId
-	// value:
-	//		key:  int(http:0, https:1)
-	//		value:
-	//			key:  int(0...n)
-	//			value: real url
-	activeServiceIpPortMap map[string]map[int]map[int]string
+	policies map[string]*appPolicy
+	// 未配置调用策略的应用使用的默认策略
+	defaultPolicy *appPolicy
+
+	// 全局对冲预算
+	hedge *hedgeBudget
 }
 
 func NewClient(configPath string) *Client {
@@ -74,14 +86,14 @@ func NewClient(configPath string) *Client {
 func NewClientWithLog(configPath string, zapLog *zap.SugaredLogger) *Client {
 	log := logger.NewLogAgent(zapLog)
 
-	eurekaConfig, err := config.LoadConfig(configPath, false)
+	eurekaConfig, err := config.LoadConfig(configPath, true)
 	if err != nil {
 		log.Error(fmt.Sprintf("LoadConfig %s failed, err=%s", configPath, err.Error()))
 		os.Exit(1)
 	}
 
 	//实例化
-	instanceInfo, err := config.NewInstance(eurekaConfig)
+	instanceInfo, err := config.NewInstanceWithLog(eurekaConfig, log)
 	if err != nil {
 		log.Error(fmt.Sprintf("NewInstance %s failed, err=%s", eurekaConfig.InstanceConfig.AppName, err.Error()))
 		os.Exit(1)
@@ -93,20 +105,59 @@ func NewClientWithLog(configPath string, zapLog *zap.SugaredLogger) *Client {
 		logger:     log,
 		signalChan: make(chan os.Signal),
 		//
-		config:   eurekaConfig,
-		instance: instanceInfo,
+		configPath: configPath,
+		loader:     newAppLoader(),
+		outliers:   newOutlierDetector(),
+		prober:     newHealthProber(&eurekaConfig.ClientConfig.HealthProbe),
+		hedge:      newHedgeBudget(eurekaConfig.ClientConfig.GetHedgeBudgetPercent()),
+	}
+	client.config.Store(eurekaConfig)
+	client.instance.Store(instanceInfo)
+
+	balancer, err := newLoadBalancer(eurekaConfig.ClientConfig.GetLoadBalancer(), client)
+	if err != nil {
+		client.logger.Error(fmt.Sprintf("Failed to create load balancer, err=%s", err.Error()))
+		os.Exit(1)
 	}
+	client.balancer = balancer
+
+	policies, err := client.newPolicies()
+	if err != nil {
+		client.logger.Error(fmt.Sprintf("Failed to create client policies, err=%s", err.Error()))
+		os.Exit(1)
+	}
+	client.policies = policies
+	client.defaultPolicy, _ = client.newPolicy(config.ClientPolicy{})
 
 	api, err := client.Api()
 	if err != nil {
 		client.logger.Error(fmt.Sprintf("Failed to get EurekaServerApi instance, err=%s", err.Error()))
 		os.Exit(1)
 	}
-	client.apiClient = api
+	client.apiClient.Store(api)
 
 	return client
 }
 
+// 复制当前实例并修改后整体替换, 返回替换后的实例
+// 已取得旧实例的读取方不受影响, 修改之间通过 mutex 串行化避免丢失更新
+func (client *Client) updateInstance(update func(instance *core.Instance)) *core.Instance {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	instance := client.instance.Load().Clone()
+	update(instance)
+	client.instance.Store(instance)
+	return instance
+}
+
+// 是否已启动且未注销, 与 Run、Shutdown 并发时通过 mutex 读取
+func (client *Client) isRunning() bool {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	return client.Running
+}
+
 func (client *Client) Run() {
 	client.mutex.Lock()
 	client.Running = true
@@ -119,21 +170,31 @@ func (client *Client) Run() {
 	// and update to t.registryAppMap
 	go client.refreshRegistry()
 
+	// (if HealthProbe enabled), probe instances of called apps periodically
+	go client.probeHealth()
+
+	// (if ConfigReloadIntervalSeconds > 0), reload config file when it changes
+	go client.watchConfig()
+
+	// (if management port is set), serve actuator endpoints on the management port
+	go client.serveManagement()
+
 	client.registerWithEureka()
 }
 
 func (client *Client) Shutdown() {
 	//client在shutdown情况下，是否显示从注册中心注销
-	if !client.Running || !client.config.ClientConfig.ShouldUnregisterOnShutdown {
+	if !client.isRunning() || !client.config.Load().ClientConfig.ShouldUnregisterOnShutdown {
 		return
 	}
 
-	client.logger.Info(fmt.Sprintf("Receive exit signal, client instance going to de-register, instanceId=%s.", client.instance.InstanceId))
+	instance := client.instance.Load()
+	client.logger.Info(fmt.Sprintf("Receive exit signal, client instance going to de-register, instanceId=%s.", instance.InstanceId))
 
 	// de-register instance
-	err := client.apiClient.DeRegisterInstance(client.instance.App, client.instance.InstanceId)
+	err := client.apiClient.Load().DeRegisterInstance(instance.App, instance.InstanceId)
 	if err != nil {
-		client.logger.Error(fmt.Sprintf("Failed to de-register %s, err=%s", client.instance.InstanceId, err.Error()))
+		client.logger.Error(fmt.Sprintf("Failed to de-register %s, err=%s", instance.InstanceId, err.Error()))
 		return
 	}
 
@@ -141,7 +202,7 @@ func (client *Client) Shutdown() {
 	client.Running = false
 	client.mutex.Unlock()
 
-	client.logger.Info(fmt.Sprintf("de-register %s success.", client.instance.InstanceId))
+	client.logger.Info(fmt.Sprintf("de-register %s success.", instance.InstanceId))
 }
 
 // for graceful kill. Here handle SIGTERM signal to do sth
@@ -166,7 +227,7 @@ func (client *Client) handleSignal() {
 		case syscall.SIGQUIT:
 			fallthrough
 		case syscall.SIGTERM:
-			client.logger.Info(fmt.Sprintf("syscall kill, instanceId=%s.", client.instance.InstanceId))
+			client.logger.Info(fmt.Sprintf("syscall kill, instanceId=%s.", client.instance.Load().InstanceId))
 			client.Shutdown()
 		}
 	}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	yaml "gopkg.in/yaml.v3"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 宽松绑定: 按 AppConfig 的yaml字段名将任意风格的属性名绑定到配置上,
+// 属性名忽略大小写及'-'、'_', 如 eureka.instance.app-name、eureka.instance.appName、EUREKA_INSTANCE_APPNAME 视为同一个属性
+// 绑定结果为yaml节点树, 最终统一解码为 AppConfig
+
+// 属性路径中的一段
+type bindStep struct {
+	// 映射的key, 结构体字段为yaml字段名, map为原始key
+	key string
+	// 序列的下标, 为-1时表示映射的key
+	index int
+	// 是否为map的key, map的key按规范化名称匹配已有的key
+	mapKey bool
+	// 该段对应的值类型
+	typ reflect.Type
+}
+
+// 按类型解析属性路径, tokens 为按'.'(环境变量为'_')拆分的属性名, 相邻的多段可以合并匹配一个字段,
+// 如 SERVICE_URL 可以匹配 serviceUrl; sep 为元数据等简单值map的key中各段的连接符
+func resolveBindPath(typ reflect.Type, tokens []string, sep string) ([]bindStep, bool) {
+	typ = indirectType(typ)
+	if 0 == len(tokens) {
+		return nil, isLeafType(typ)
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name := yamlName(field)
+			if 0 == len(name) {
+				continue
+			}
+
+			target := canonicalName(name)
+			joined := ""
+			for k := 1; k <= len(tokens); k++ {
+				joined += canonicalName(tokens[k-1])
+				if !strings.HasPrefix(target, joined) {
+					break
+				}
+				if target != joined {
+					continue
+				}
+				if rest, ok := resolveBindPath(field.Type, tokens[k:], sep); ok {
+					return append([]bindStep{{key: name, index: -1, typ: field.Type}}, rest...), true
+				}
+			}
+		}
+	case reflect.Map:
+		elem := typ.Elem()
+		if isLeafType(indirectType(elem)) {
+			//元数据等简单值的map, 剩余部分均为key, 如 metadata-map.management.port
+			return []bindStep{{key: strings.Join(tokens, sep), index: -1, mapKey: true, typ: elem}}, true
+		}
+		//应用名等复杂值的map, key 可能由多段组成, 如 EUREKA_CLIENTS_DEMO_SERVICE_READTIMEOUTMS
+		for k := 1; k <= len(tokens); k++ {
+			if rest, ok := resolveBindPath(elem, tokens[k:], sep); ok {
+				return append([]bindStep{{key: strings.Join(tokens[:k], "-"), index: -1, mapKey: true, typ: elem}}, rest...), true
+			}
+		}
+	case reflect.Slice:
+		index, err := strconv.Atoi(tokens[0])
+		if nil != err || 0 > index {
+			return nil, false
+		}
+		if rest, ok := resolveBindPath(typ.Elem(), tokens[1:], sep); ok {
+			return append([]bindStep{{index: index, typ: typ.Elem()}}, rest...), true
+		}
+	}
+	return nil, false
+}
+
+// 将属性值写入yaml节点树, 覆盖已有的值
+func bindValue(root *yaml.Node, steps []bindStep, value *yaml.Node) {
+	node := documentMapping(root)
+	for i, step := range steps {
+		last := len(steps)-1 == i
+
+		var child *yaml.Node
+		if 0 <= step.index {
+			if yaml.SequenceNode != node.Kind {
+				*node = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			}
+			for len(node.Content) <= step.index {
+				node.Content = append(node.Content, newNodeFor(step.typ))
+			}
+			child = node.Content[step.index]
+		} else {
+			child = mappingValue(node, step.key, step.mapKey, step.typ)
+		}
+
+		if last {
+			*child = *leafNode(step.typ, value)
+			return
+		}
+		node = child
+	}
+}
+
+// 按属性路径查找yaml节点树中已有的值, 不存在时返回nil
+func lookupValue(root *yaml.Node, steps []bindStep) *yaml.Node {
+	node := documentMapping(root)
+	for _, step := range steps {
+		var child *yaml.Node
+		if 0 <= step.index {
+			if yaml.SequenceNode == node.Kind && step.index < len(node.Content) {
+				child = node.Content[step.index]
+			}
+		} else if yaml.MappingNode == node.Kind {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if existing := node.Content[i].Value; existing == step.key || (step.mapKey && sameMapKey(existing, step.key)) {
+					child = node.Content[i+1]
+					break
+				}
+			}
+		}
+		if nil == child {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
+
+// 文档节点下的根映射, 不存在时创建
+func documentMapping(root *yaml.Node) *yaml.Node {
+	if yaml.DocumentNode != root.Kind {
+		*root = yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if 0 == len(root.Content) || yaml.MappingNode != root.Content[0].Kind {
+		root.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	return root.Content[0]
+}
+
+// 映射中key对应的值节点, 不存在或类型不符时创建
+func mappingValue(node *yaml.Node, key string, mapKey bool, typ reflect.Type) *yaml.Node {
+	if yaml.MappingNode != node.Kind {
+		*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		existing := node.Content[i].Value
+		if existing == key || (mapKey && sameMapKey(existing, key)) {
+			child := node.Content[i+1]
+			if want := newNodeFor(typ); yaml.ScalarNode != want.Kind && want.Kind != child.Kind {
+				*child = *want
+			}
+			return child
+		}
+	}
+
+	child := newNodeFor(typ)
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+	return child
+}
+
+// map的key忽略大小写及'.'、'-'、'_', 如环境变量中的 demo.service 对应配置文件中的 DEMO-SERVICE
+func sameMapKey(a string, b string) bool {
+	return strings.ReplaceAll(canonicalName(a), ".", "") == strings.ReplaceAll(canonicalName(b), ".", "")
+}
+
+func newNodeFor(typ reflect.Type) *yaml.Node {
+	switch indirectType(typ).Kind() {
+	case reflect.Struct, reflect.Map:
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	case reflect.Slice:
+		return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode}
+	}
+}
+
+// 切片字段的值为字符串时按','拆分, 如 apps=DEMO,OTHER;
+// 元数据等 interface{} 类型的值未指定类型时按字符串处理
+func leafNode(typ reflect.Type, value *yaml.Node) *yaml.Node {
+	if yaml.ScalarNode != value.Kind {
+		return value
+	}
+
+	kind := indirectType(typ).Kind()
+	if reflect.Interface == kind && 0 == len(value.Tag) {
+		str := *value
+		str.Tag = "!!str"
+		return &str
+	}
+	if reflect.Slice != kind {
+		return value
+	}
+
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: value.Line, Column: value.Column}
+	for _, item := range strings.Split(value.Value, ",") {
+		if item = strings.TrimSpace(item); 0 < len(item) {
+			seq.Content = append(seq.Content, plainScalar(item))
+		}
+	}
+	return seq
+}
+
+// 未指定类型的标量, 解码时按值推断类型
+func plainScalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+}
+
+// 可以直接由标量或序列赋值的类型
+func isLeafType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Map:
+		return false
+	case reflect.Slice:
+		return isLeafType(indirectType(typ.Elem()))
+	default:
+		return true
+	}
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	for reflect.Ptr == typ.Kind() {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// 结构体字段的yaml名, 忽略的字段返回空串
+func yamlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if "-" == name {
+		return ""
+	}
+	if 0 == len(name) {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
@@ -2,28 +2,42 @@ package config
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
-	yaml "gopkg.in/yaml.v3"
 	core "github.com/phpdragon/go-eureka-client/core"
+	"github.com/phpdragon/go-eureka-client/logger"
 	netUtil "github.com/phpdragon/go-eureka-client/netutil"
+	yaml "gopkg.in/yaml.v3"
 	"io/ioutil"
+	"net"
 	"os"
-	"reflect"
 	"strconv"
 	"strings"
 	template "text/template"
 )
 
+const (
+	//轮询
+	LoadBalancerRoundRobin = "roundRobin"
+	//随机
+	LoadBalancerRandom = "random"
+	//按延迟，P2C + PeakEWMA，须通过 Report 上报每次调用结果
+	LoadBalancerPeakEwma = "peakEwma"
+
+	//预热权重线性增长
+	WarmupCurveLinear = "linear"
+	//预热权重指数增长
+	WarmupCurveExponential = "exponential"
+
+	//通过http端口调用
+	SchemeHttp = "http"
+	//通过https端口调用，只选择启用了https端口的实例
+	SchemeHttps = "https"
+)
+
 type templateData struct {
 	Env map[string]string
 }
 
-type inValid struct {
-	Name string
-	Type string
-}
-
 type (
 	AppConfig struct {
 		Server struct {
@@ -37,9 +51,15 @@ type (
 		ServiceURL struct {
 			DefaultZone string `yaml:"defaultZone"`
 		} `yaml:"serviceUrl"`
-		ClientConfig   ClientConfig `yaml:"client"`
+		ClientConfig ClientConfig `yaml:"client"`
+		//下游应用的调用策略
+		//key: appId
+		Clients        map[string]ClientPolicy `yaml:"clients"`
 		InstanceConfig struct {
-			InstanceId            string `yaml:"instanceId"`
+			//实例ID，支持模板变量 {appName}、{hostname}、{ip}、{port}、{random}、{podName}，为空则默认 ip(或hostname):port
+			InstanceId string `yaml:"instanceId"`
+			//启动注册前发现其他主机的实例已使用相同的实例ID时的处理: warn(默认，告警后继续注册)、refuse(不注册)、ignore(不检查)
+			InstanceIdConflict    string `yaml:"instanceIdConflict"`
 			AppName               string `yaml:"appName"`
 			NonSecurePort         int    `yaml:"nonSecurePort"`
 			NonSecurePortEnabled  bool   `yaml:"nonSecurePortEnabled"`
@@ -51,13 +71,28 @@ type (
 			HomePageUrlPath    string `yaml:"homePageUrlPath"`
 			StatusPageUrlPath  string `yaml:"statusPageUrlPath"`
 			HealthCheckUrlPath string `yaml:"healthCheckUrlPath"`
+			//完整地址，配置后优先于对应的相对地址，如 https://gateway.example.com/actuator/health
+			HomePageUrl          string `yaml:"homePageUrl"`
+			StatusPageUrl        string `yaml:"statusPageUrl"`
+			HealthCheckUrl       string `yaml:"healthCheckUrl"`
+			SecureHealthCheckUrl string `yaml:"secureHealthCheckUrl"`
 			//
-			PreferIpAddress       bool `yaml:"preferIpAddress"`
-			InstanceEnabledOnInit bool `yaml:"instanceEnabledOnInit"`
+			PreferIpAddress bool `yaml:"preferIpAddress"`
+			//注册的主机名，为空则取本机hostname
+			Hostname string `yaml:"hostname"`
+			//actuator 管理端点，同 spring boot 的 management.*
+			Management ManagementConfig `yaml:"management"`
+			//注册的ip地址，为空则按 inetutils 的规则选择本机ip
+			IpAddress string `yaml:"ipAddress"`
+			//选择本机ip的规则，同 spring.cloud.inetutils.*
+			Inetutils             InetutilsConfig `yaml:"inetutils"`
+			InstanceEnabledOnInit bool            `yaml:"instanceEnabledOnInit"`
 			//
-			CountryId int                    `yaml:"countryId"`
-			Metadata  map[string]interface{} `yaml:"metadata"`
-			LeaseInfo struct {
+			CountryId int `yaml:"countryId"`
+			//所属数据中心
+			DataCenterInfo DataCenterInfoConfig   `yaml:"dataCenterInfo"`
+			Metadata       map[string]interface{} `yaml:"metadata"`
+			LeaseInfo      struct {
 				RenewalIntervalInSecs int `yaml:"renewalIntervalInSecs"`
 				DurationInSecs        int `yaml:"durationInSecs"`
 			} `yaml:"leaseInfo"`
@@ -75,9 +110,171 @@ type (
 		RegisterWithEureka bool `yaml:"registerWithEureka"`
 		//client在shutdown情况下，是否显示从注册中心注销
 		ShouldUnregisterOnShutdown bool `yaml:"shouldUnregisterOnShutdown"`
+		//负载均衡策略: roundRobin(默认)、random、peakEwma
+		LoadBalancer string `yaml:"loadBalancer"`
+		//peakEwma 策略的延迟衰减时间，默认10s
+		EwmaDecaySeconds int `yaml:"ewmaDecaySeconds"`
+		//新实例预热
+		Warmup WarmupConfig `yaml:"warmup"`
+		//按需查询时应用不存在的结果缓存时长，默认30s，小于0则不缓存
+		NotFoundCacheTtlSeconds int `yaml:"notFoundCacheTtlSeconds"`
+		//故障实例摘除
+		OutlierDetection OutlierDetectionConfig `yaml:"outlierDetection"`
+		//主动健康检查
+		HealthProbe HealthProbeConfig `yaml:"healthProbe"`
+		//对冲请求数占可对冲请求总数的上限(%)，所有应用共用，默认10
+		HedgeBudgetPercent int `yaml:"hedgeBudgetPercent"`
+		//检查配置文件修改的间隔，修改后热更新注册中心地址、元数据、租约及获取注册表间隔，默认0不检查
+		ConfigReloadIntervalSeconds int `yaml:"configReloadIntervalSeconds"`
+	}
+
+	//故障实例摘除配置，根据上报的调用结果临时摘除连续失败或错误率过高的实例
+	OutlierDetectionConfig struct {
+		//连续失败多少次后摘除，默认5，小于0则不按连续失败摘除
+		ConsecutiveFailures int `yaml:"consecutiveFailures"`
+		//统计周期内错误率达到多少(%)后摘除，默认50，小于0则不按错误率摘除
+		FailureRatePercent int `yaml:"failureRatePercent"`
+		//统计周期内请求数达到多少才计算错误率，默认20
+		MinRequests int `yaml:"minRequests"`
+		//错误率统计周期，默认10s
+		IntervalSeconds int `yaml:"intervalSeconds"`
+		//首次摘除时长，再次摘除时按指数递增，默认30s
+		BaseEjectionSeconds int `yaml:"baseEjectionSeconds"`
+		//最长摘除时长，默认300s
+		MaxEjectionSeconds int `yaml:"maxEjectionSeconds"`
+		//同一应用最多摘除的实例比例(%)，默认50，小于0则不摘除
+		MaxEjectionPercent int `yaml:"maxEjectionPercent"`
+	}
+
+	//下游应用的调用策略，作用于 Transport 及实例选择，类似 ribbon 的 <app>.ribbon.*
+	ClientPolicy struct {
+		//Invoke 调用该应用使用的协议: http(默认)、https，https时只选择启用了https端口的实例
+		Scheme string `yaml:"scheme"`
+		//建立连接超时时间，仅在 Transport 未指定 Base 时生效，默认0不限制
+		ConnectTimeoutMs int `yaml:"connectTimeoutMs"`
+		//单次请求(含读取响应体)超时时间，默认0不限制
+		ReadTimeoutMs int `yaml:"readTimeoutMs"`
+		//同一实例上的重试次数，不含首次请求，默认0
+		MaxAutoRetries int `yaml:"maxAutoRetries"`
+		//切换实例重试的次数，默认1，小于0则不切换
+		MaxAutoRetriesNextServer int `yaml:"maxAutoRetriesNextServer"`
+		//是否对非幂等请求(POST、PATCH)重试，默认为false
+		OkToRetryOnAllOperations bool `yaml:"okToRetryOnAllOperations"`
+		//需要重试的响应码，默认只在请求出错时重试
+		RetryableStatusCodes []int `yaml:"retryableStatusCodes"`
+		//负载均衡策略，为空则使用 client.loadBalancer
+		LoadBalancer string `yaml:"loadBalancer"`
+		//只选择元数据匹配的实例
+		MetadataFilters map[string]string `yaml:"metadataFilters"`
+		//是否优先选择与当前实例 metadata.zone 相同的实例，默认为false
+		PreferSameZone bool `yaml:"preferSameZone"`
+		//对该应用同时进行中的最大请求数，超过则立即拒绝，默认0不限制
+		MaxConcurrentRequests int `yaml:"maxConcurrentRequests"`
+		//对该应用单个实例同时进行中的最大请求数，超过则换一个实例，默认0不限制
+		MaxConcurrentRequestsPerInstance int `yaml:"maxConcurrentRequestsPerInstance"`
+		//每秒允许的请求数(令牌桶)，超过则立即拒绝，默认0不限制
+		RateLimitPerSecond float64 `yaml:"rateLimitPerSecond"`
+		//令牌桶容量，默认等于 rateLimitPerSecond(至少为1)
+		RateLimitBurst int `yaml:"rateLimitBurst"`
+		//是否开启对冲请求，幂等请求超过对冲延迟未返回时向另一个实例再发一次，取先返回的结果，默认为false
+		HedgeEnabled bool `yaml:"hedgeEnabled"`
+		//对冲延迟取最近成功调用延迟的百分位，默认95
+		HedgeDelayPercentile float64 `yaml:"hedgeDelayPercentile"`
+		//对冲延迟的下限，默认10ms
+		HedgeMinDelayMs int `yaml:"hedgeMinDelayMs"`
+	}
+
+	//新实例预热配置，新上线的实例在预热时长内权重逐渐增加到100%
+	WarmupConfig struct {
+		//预热时长，默认0不预热
+		Seconds int `yaml:"seconds"`
+		//权重增长曲线: linear(默认)、exponential
+		Curve string `yaml:"curve"`
+		//预热开始时的权重(%)，默认10
+		MinWeightPercent int `yaml:"minWeightPercent"`
+	}
+
+	//主动健康检查配置，定时请求已调用应用实例的healthCheckUrl，失败的实例不会被选中
+	HealthProbeConfig struct {
+		//是否开启，默认为false
+		Enabled bool `yaml:"enabled"`
+		//需要检查的应用(appId)，只检查其中被调用过的应用
+		Apps []string `yaml:"apps"`
+		//检查间隔，默认10s
+		IntervalSeconds int `yaml:"intervalSeconds"`
+		//单次检查超时时间，默认3s
+		TimeoutSeconds int `yaml:"timeoutSeconds"`
+		//同时检查的实例数，默认4
+		Concurrency int `yaml:"concurrency"`
+		//连续失败多少次后标记为不健康，默认2
+		UnhealthyThreshold int `yaml:"unhealthyThreshold"`
+	}
+
+	//actuator 管理端点配置，也可以使用 spring boot 的 management.port(management.server.port)、
+	//management.basePath(management.endpoints.web.base-path、management.context-path)
+	ManagementConfig struct {
+		//独立的管理端口，配置后注册的状态及健康检查地址使用该端口，Client.Run 时在该端口启动 actuator 服务，默认0与服务端口相同
+		Port int `yaml:"port"`
+		//actuator 端点的路径前缀，默认/actuator
+		BasePath string `yaml:"basePath"`
+	}
+
+	//选择本机ip的规则，按网卡序号从小到大选择第一个符合规则的地址
+	InetutilsConfig struct {
+		//忽略的网卡名，正则表达式，如 docker0、veth.*
+		IgnoredInterfaces []string `yaml:"ignoredInterfaces"`
+		//优先的网段，CIDR(如10.0.0.0/8)或ip前缀(如192.168)，配置后只选择匹配的地址
+		PreferredNetworks []string `yaml:"preferredNetworks"`
+		//只选择内网地址，默认为false
+		UseOnlySiteLocalInterfaces bool `yaml:"useOnlySiteLocalInterfaces"`
+		//优先选择ipv6地址，默认优先ipv4
+		PreferIpv6 bool `yaml:"preferIpv6"`
+	}
+
+	//数据中心配置
+	DataCenterInfoConfig struct {
+		//数据中心名称: MyOwn(默认)、Amazon，为Amazon时从EC2实例元数据服务(IMDSv2)获取元数据
+		Name string `yaml:"name"`
+		//实例元数据服务地址，默认http://169.254.169.254
+		MetadataEndpoint string `yaml:"metadataEndpoint"`
+		//获取每项实例元数据的超时时间，默认1000ms
+		MetadataTimeoutMs int `yaml:"metadataTimeoutMs"`
+		//获取实例元数据失败时是否启动失败，默认为false，记录警告并退回MyOwn
+		FailFast bool `yaml:"failFast"`
+		//数据中心元数据，配置了的项覆盖从实例元数据服务获取的值
+		Metadata DataCenterMetadataConfig `yaml:"metadata"`
+	}
+
+	DataCenterMetadataConfig struct {
+		AmiLaunchIndex   string `yaml:"amiLaunchIndex"`
+		LocalHostname    string `yaml:"localHostname"`
+		AvailabilityZone string `yaml:"availabilityZone"`
+		InstanceId       string `yaml:"instanceId"`
+		PublicIpv4       string `yaml:"publicIpv4"`
+		PublicHostname   string `yaml:"publicHostname"`
+		AmiManifestPath  string `yaml:"amiManifestPath"`
+		LocalIpv4        string `yaml:"localIpv4"`
+		Hostname         string `yaml:"hostname"`
+		AmiID            string `yaml:"amiId"`
+		InstanceType     string `yaml:"instanceType"`
 	}
 )
 
+// LoadConfig 加载yaml或 .properties 格式的配置文件
+// 属性名支持宽松绑定(kebab-case、camelCase、下划线), 兼容 spring cloud eureka 的属性名,
+// 如 eureka.client.service-url.defaultZone、eureka.instance.metadata-map、spring.application.name
+// 所有配置项都可以通过环境变量覆盖, 变量名为属性名去掉'-'后'.'替换为'_'并转为大写,
+// 如 EUREKA_CLIENT_SERVICEURL_DEFAULTZONE、EUREKA_INSTANCE_APPNAME、EUREKA_INSTANCE_LEASEINFO_DURATIONINSECS、
+// EUREKA_INSTANCE_METADATA_ZONE、EUREKA_CLIENTS_DEMO_READTIMEOUTMS、SERVER_PORT
+// 优先级从高到低:
+//  1. 环境变量
+//  2. 配置文件中的属性
+//  3. spring boot 属性: spring.application.name 作为 eureka.instance.appName, server.port 作为 eureka.instance.nonSecurePort,
+//     同样可以来自环境变量(SPRING_APPLICATION_NAME、SERVER_PORT), 但不会覆盖显式配置的 eureka 属性;
+//     需要 SERVER_PORT 生效时配置为 nonSecurePort: ${server.port}, 见 config_sample.yaml
+//  4. 各配置项的默认值, 见各 Get 方法及 Config.Validate
+//
+// 配置文件中的 ${...} 占位符在绑定前解析, 同样优先取环境变量
 func LoadConfig(configPath string, valid bool) (*Config, error) {
 	file, err := ioutil.ReadFile(configPath)
 	if err != nil {
@@ -89,14 +286,29 @@ func LoadConfig(configPath string, valid bool) (*Config, error) {
 		return &Config{}, err
 	}
 
+	node := &yaml.Node{}
+	if isPropertiesFile(configPath) {
+		node, err = parseProperties(file)
+	} else {
+		err = yaml.Unmarshal(file, node)
+	}
+	if err != nil {
+		return &Config{}, fmt.Errorf("Failed to parse %s: %w", configPath, err)
+	}
+
+	if err = resolvePlaceholders(node); err != nil {
+		return &Config{}, fmt.Errorf("Failed to resolve placeholders in %s: %w", configPath, err)
+	}
+
 	config := &AppConfig{}
-	if err = yaml.Unmarshal(file, config); err != nil {
-		return &Config{}, err
+	if err = bindDocument(node, os.Environ()).Decode(config); err != nil {
+		return &Config{}, fmt.Errorf("Failed to bind %s: %w", configPath, err)
 	}
+	config.Eureka.applyDefaults()
 
 	if valid {
-		if err = validate(config); err != nil {
-			return &Config{}, err
+		if err = config.Eureka.Validate(); err != nil {
+			return &Config{}, fmt.Errorf("Invalid config %s: %w", configPath, err)
 		}
 	}
 
@@ -104,16 +316,26 @@ func LoadConfig(configPath string, valid bool) (*Config, error) {
 }
 
 func NewInstance(config *Config) (*core.Instance, error) {
+	return NewInstanceWithLog(config, logger.NewLogAgent(nil))
+}
+
+// 创建注册的实例, 获取数据中心信息失败等可以继续启动的问题通过 log 记录
+func NewInstanceWithLog(config *Config, log *logger.Logger) (*core.Instance, error) {
 	if isEmpty(config.InstanceConfig.AppName) {
 		return nil, fmt.Errorf("eureka.instance.appName is empty！")
 	}
 
-	//是否优先使用服务实例的IP地址，相较于
+	ipAddress, err := localIpAddress(config)
+	if nil != err {
+		return nil, err
+	}
+
+	//是否优先使用服务实例的IP地址，相较于hostname
 	var localIp = ""
 	if config.InstanceConfig.PreferIpAddress {
-		localIp = netUtil.GetLocalIp()
+		localIp = ipAddress
 	} else {
-		hostname, err := os.Hostname()
+		hostname, err := localHostname(config)
 		if nil != err {
 			return &core.Instance{}, err
 		}
@@ -121,10 +343,15 @@ func NewInstance(config *Config) (*core.Instance, error) {
 		localIp = hostname
 	}
 
+	dataCenterInfo, err := newDataCenterInfo(&config.InstanceConfig.DataCenterInfo, log)
+	if nil != err {
+		return nil, err
+	}
+
 	instance := &core.Instance{
 		InstanceId:       config.InstanceConfig.InstanceId,
 		HostName:         localIp,
-		IpAddr:           localIp,
+		IpAddr:           ipAddress,
 		App:              config.InstanceConfig.AppName,
 		VipAddress:       config.InstanceConfig.VirtualHostName,
 		SecureVipAddress: config.InstanceConfig.SecureVirtualHostName,
@@ -141,24 +368,8 @@ func NewInstance(config *Config) (*core.Instance, error) {
 		StatusPageUrl:  config.InstanceConfig.StatusPageUrlPath,
 		HealthCheckUrl: config.InstanceConfig.HealthCheckUrlPath,
 		// 数据中心
-		DataCenterInfo: &core.DataCenterInfo{
-			Class: "com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo",
-			Name:  core.DC_NAME_TYPE_MY_OWN,
-			//Metadata: &core.DataCenterMetadata{
-			//	AmiLaunchIndex:   config.InstanceConfig.DataCenterInfo.Metadata.AmiLaunchIndex,
-			//	LocalHostname:    config.InstanceConfig.DataCenterInfo.Metadata.LocalHostname,
-			//	AvailabilityZone: config.InstanceConfig.DataCenterInfo.Metadata.AvailabilityZone,
-			//	InstanceId:       config.InstanceConfig.DataCenterInfo.Metadata.InstanceId,
-			//	PublicIpv4:       config.InstanceConfig.DataCenterInfo.Metadata.PublicIpv4,
-			//	PublicHostname:   config.InstanceConfig.DataCenterInfo.Metadata.PublicHostname,
-			//	AmiManifestPath:  config.InstanceConfig.DataCenterInfo.Metadata.AmiManifestPath,
-			//	LocalIpv4:        config.InstanceConfig.DataCenterInfo.Metadata.LocalIpv4,
-			//	Hostname:         config.InstanceConfig.DataCenterInfo.Metadata.Hostname,
-			//	AmiID:            config.InstanceConfig.DataCenterInfo.Metadata.AmiID,
-			//	InstanceType:     config.InstanceConfig.DataCenterInfo.Metadata.InstanceType,
-			//},
-		},
-		Metadata: config.InstanceConfig.Metadata,
+		DataCenterInfo: dataCenterInfo,
+		Metadata:       config.InstanceMetadata(),
 		LeaseInfo: &core.LeaseInfo{
 			RenewalIntervalInSecs: config.InstanceConfig.LeaseInfo.RenewalIntervalInSecs,
 			DurationInSecs:        config.InstanceConfig.LeaseInfo.DurationInSecs,
@@ -171,13 +382,26 @@ func NewInstance(config *Config) (*core.Instance, error) {
 		instance.DataCenterInfo.Name = core.DC_NAME_TYPE_MY_OWN
 	}
 
-	port := config.InstanceConfig.NonSecurePort
+	//启用https端口时实例地址使用https
+	scheme, port := "http", config.InstanceConfig.NonSecurePort
 	if config.InstanceConfig.SecurePortEnabled {
-		port = config.InstanceConfig.SecurePort
+		scheme, port = "https", config.InstanceConfig.SecurePort
 	}
 
 	if isEmpty(config.InstanceConfig.InstanceId) {
 		instance.InstanceId = fmt.Sprintf("%s:%d", localIp, port)
+	} else {
+		instance.InstanceId, err = expandInstanceId(strings.TrimSpace(config.InstanceConfig.InstanceId), instanceIdValues{
+			appName: config.InstanceConfig.AppName,
+			ip:      ipAddress,
+			port:    port,
+			hostname: func() (string, error) {
+				return localHostname(config)
+			},
+		})
+		if nil != err {
+			return nil, err
+		}
 	}
 	if isEmpty(instance.HostName) {
 		instance.HostName = localIp
@@ -198,19 +422,90 @@ func NewInstance(config *Config) (*core.Instance, error) {
 	if isEmpty(instance.HomePageUrl) {
 		instance.HomePageUrl = ""
 	}
+	management := &config.InstanceConfig.Management
 	if isEmpty(instance.StatusPageUrl) {
-		instance.StatusPageUrl = "/actuator/info"
+		instance.StatusPageUrl = management.GetBasePath() + "/info"
 	}
 	if isEmpty(instance.HealthCheckUrl) {
-		instance.HealthCheckUrl = "/actuator/health"
+		instance.HealthCheckUrl = management.GetBasePath() + "/health"
+	}
+	healthCheckUrlPath := instance.HealthCheckUrl
+	instance.HomePageUrl = instanceUrl(config.InstanceConfig.HomePageUrl, scheme, localIp, port, instance.HomePageUrl)
+
+	//独立的管理端口使用http
+	if config.separateManagementPort() {
+		instance.StatusPageUrl = instanceUrl(config.InstanceConfig.StatusPageUrl, "http", localIp, management.Port, instance.StatusPageUrl)
+		instance.HealthCheckUrl = instanceUrl(config.InstanceConfig.HealthCheckUrl, "http", localIp, management.Port, healthCheckUrlPath)
+		instance.SecureHealthCheckUrl = strings.TrimSpace(config.InstanceConfig.SecureHealthCheckUrl)
+	} else {
+		instance.StatusPageUrl = instanceUrl(config.InstanceConfig.StatusPageUrl, scheme, localIp, port, instance.StatusPageUrl)
+		instance.HealthCheckUrl = instanceUrl(config.InstanceConfig.HealthCheckUrl, scheme, localIp, port, healthCheckUrlPath)
+		if config.InstanceConfig.SecurePortEnabled || !isEmpty(config.InstanceConfig.SecureHealthCheckUrl) {
+			instance.SecureHealthCheckUrl = instanceUrl(config.InstanceConfig.SecureHealthCheckUrl, "https", localIp, config.InstanceConfig.SecurePort, healthCheckUrlPath)
+		}
 	}
-	instance.HomePageUrl = fmt.Sprintf("http://%s:%d/%s", localIp, port, strings.TrimLeft(instance.HomePageUrl, "/"))
-	instance.StatusPageUrl = fmt.Sprintf("http://%s:%d/%s", localIp, port, strings.TrimLeft(instance.StatusPageUrl, "/"))
-	instance.HealthCheckUrl = fmt.Sprintf("http://%s:%d/%s", localIp, port, strings.TrimLeft(instance.HealthCheckUrl, "/"))
 
 	return instance, nil
 }
 
+// InstanceMetadata 注册的实例元数据, 使用独立的管理端口时同 spring cloud 添加 management.port
+func (config *Config) InstanceMetadata() map[string]interface{} {
+	if !config.separateManagementPort() {
+		return config.InstanceConfig.Metadata
+	}
+
+	metadata := make(map[string]interface{}, len(config.InstanceConfig.Metadata)+1)
+	for k, v := range config.InstanceConfig.Metadata {
+		metadata[k] = v
+	}
+	metadata["management.port"] = strconv.Itoa(config.InstanceConfig.Management.Port)
+	return metadata
+}
+
+// 是否配置了与服务端口不同的管理端口
+func (config *Config) separateManagementPort() bool {
+	port := config.InstanceConfig.NonSecurePort
+	if config.InstanceConfig.SecurePortEnabled {
+		port = config.InstanceConfig.SecurePort
+	}
+	return 0 < config.InstanceConfig.Management.Port && port != config.InstanceConfig.Management.Port
+}
+
+// 实例的完整地址, 配置了完整地址时直接使用, 否则由主机名、端口和相对地址拼接
+func instanceUrl(absoluteUrl string, scheme string, host string, port int, path string) string {
+	if !isEmpty(absoluteUrl) {
+		return strings.TrimSpace(absoluteUrl)
+	}
+	return fmt.Sprintf("%s://%s/%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)), strings.TrimLeft(path, "/"))
+}
+
+// 注册的主机名, 优先使用配置的 hostname
+func localHostname(config *Config) (string, error) {
+	if !isEmpty(config.InstanceConfig.Hostname) {
+		return strings.TrimSpace(config.InstanceConfig.Hostname), nil
+	}
+	return os.Hostname()
+}
+
+// 注册的ip地址, 优先使用配置的 ipAddress
+func localIpAddress(config *Config) (string, error) {
+	if !isEmpty(config.InstanceConfig.IpAddress) {
+		return strings.TrimSpace(config.InstanceConfig.IpAddress), nil
+	}
+
+	inetutils := config.InstanceConfig.Inetutils
+	ip, err := netUtil.FindLocalIp(netUtil.InetOptions{
+		IgnoredInterfaces:          inetutils.IgnoredInterfaces,
+		PreferredNetworks:          inetutils.PreferredNetworks,
+		UseOnlySiteLocalInterfaces: inetutils.UseOnlySiteLocalInterfaces,
+		PreferIpv6:                 inetutils.PreferIpv6,
+	})
+	if nil != err {
+		return "", fmt.Errorf("Failed to find local ip address: %w", err)
+	}
+	return ip, nil
+}
+
 func isEmpty(str string) bool {
 	if 0 == len(str) {
 		return true
@@ -225,7 +520,8 @@ func isEmpty(str string) bool {
 // NewInstance 创建服务实例
 func NewDefaultInstance() *core.Instance {
 	port := 8080
-	ip := netUtil.GetLocalIp()
+	//与注册的ip地址使用相同的规则, 找不到时为空
+	ip, _ := localIpAddress(&Config{})
 	instance := &core.Instance{
 		InstanceId:       ip + ":" + strconv.Itoa(port),
 		HostName:         ip,
@@ -263,14 +559,241 @@ func NewDefaultInstance() *core.Instance {
 	return instance
 }
 
-//指示从eureka服务器获取注册表信息的频率,默认30秒
+// 指示从eureka服务器获取注册表信息的频率,默认30秒
 func (config *ClientConfig) GetRegistryFetchIntervalSeconds() int {
 	if 0 >= config.RegistryFetchIntervalSeconds {
-		return 30
+		return DefaultRegistryFetchIntervalSeconds
 	}
 	return config.RegistryFetchIntervalSeconds
 }
 
+// 负载均衡策略,默认轮询
+func (config *ClientConfig) GetLoadBalancer() string {
+	if isEmpty(config.LoadBalancer) {
+		return LoadBalancerRoundRobin
+	}
+	return config.LoadBalancer
+}
+
+// peakEwma 策略的延迟衰减时间,默认10秒
+func (config *ClientConfig) GetEwmaDecaySeconds() int {
+	if 0 >= config.EwmaDecaySeconds {
+		return 10
+	}
+	return config.EwmaDecaySeconds
+}
+
+// 按需查询时应用不存在的结果缓存时长,默认30秒,小于0则不缓存
+func (config *ClientConfig) GetNotFoundCacheTtlSeconds() int {
+	return intOrDefault(config.NotFoundCacheTtlSeconds, 30)
+}
+
+// 对冲请求数占可对冲请求总数的上限(%),默认10,小于0则不对冲
+func (config *ClientConfig) GetHedgeBudgetPercent() int {
+	percent := intOrDefault(config.HedgeBudgetPercent, 10)
+	if 100 < percent {
+		return 100
+	}
+	return percent
+}
+
+// 连续失败多少次后摘除,默认5,小于0则不按连续失败摘除
+func (config *OutlierDetectionConfig) GetConsecutiveFailures() int {
+	return intOrDefault(config.ConsecutiveFailures, 5)
+}
+
+// 统计周期内错误率达到多少(%)后摘除,默认50,小于0则不按错误率摘除
+func (config *OutlierDetectionConfig) GetFailureRatePercent() int {
+	return intOrDefault(config.FailureRatePercent, 50)
+}
+
+// 统计周期内请求数达到多少才计算错误率,默认20
+func (config *OutlierDetectionConfig) GetMinRequests() int {
+	if 0 >= config.MinRequests {
+		return 20
+	}
+	return config.MinRequests
+}
+
+// 错误率统计周期,默认10秒
+func (config *OutlierDetectionConfig) GetIntervalSeconds() int {
+	if 0 >= config.IntervalSeconds {
+		return 10
+	}
+	return config.IntervalSeconds
+}
+
+// 首次摘除时长,默认30秒
+func (config *OutlierDetectionConfig) GetBaseEjectionSeconds() int {
+	if 0 >= config.BaseEjectionSeconds {
+		return 30
+	}
+	return config.BaseEjectionSeconds
+}
+
+// 最长摘除时长,默认300秒
+func (config *OutlierDetectionConfig) GetMaxEjectionSeconds() int {
+	if 0 >= config.MaxEjectionSeconds {
+		return 300
+	}
+	return config.MaxEjectionSeconds
+}
+
+// 同一应用最多摘除的实例比例(%),默认50,小于0则不摘除
+func (config *OutlierDetectionConfig) GetMaxEjectionPercent() int {
+	percent := intOrDefault(config.MaxEjectionPercent, 50)
+	if 100 < percent {
+		return 100
+	}
+	return percent
+}
+
+// 切换实例重试的次数,默认1,小于0则不切换
+func (policy *ClientPolicy) GetMaxAutoRetriesNextServer() int {
+	return intOrDefault(policy.MaxAutoRetriesNextServer, 1)
+}
+
+// Invoke 调用使用的协议,默认http
+func (policy *ClientPolicy) GetScheme() string {
+	if isEmpty(policy.Scheme) {
+		return SchemeHttp
+	}
+	return policy.Scheme
+}
+
+// 同一实例上的重试次数,默认0
+func (policy *ClientPolicy) GetMaxAutoRetries() int {
+	if 0 > policy.MaxAutoRetries {
+		return 0
+	}
+	return policy.MaxAutoRetries
+}
+
+// 令牌桶容量,默认等于每秒允许的请求数,至少为1
+func (policy *ClientPolicy) GetRateLimitBurst() int {
+	if 0 < policy.RateLimitBurst {
+		return policy.RateLimitBurst
+	}
+	if 1 > policy.RateLimitPerSecond {
+		return 1
+	}
+	return int(policy.RateLimitPerSecond)
+}
+
+// 对冲延迟取最近成功调用延迟的百分位,默认95
+func (policy *ClientPolicy) GetHedgeDelayPercentile() float64 {
+	if 0 >= policy.HedgeDelayPercentile || 100 < policy.HedgeDelayPercentile {
+		return 95
+	}
+	return policy.HedgeDelayPercentile
+}
+
+// 对冲延迟的下限,默认10毫秒
+func (policy *ClientPolicy) GetHedgeMinDelayMs() int {
+	if 0 >= policy.HedgeMinDelayMs {
+		return 10
+	}
+	return policy.HedgeMinDelayMs
+}
+
+// 预热权重增长曲线,默认线性
+func (config *WarmupConfig) GetCurve() string {
+	if isEmpty(config.Curve) {
+		return WarmupCurveLinear
+	}
+	return config.Curve
+}
+
+// 预热开始时的权重(%),默认10
+func (config *WarmupConfig) GetMinWeightPercent() int {
+	if 0 >= config.MinWeightPercent {
+		return 10
+	}
+	if 100 < config.MinWeightPercent {
+		return 100
+	}
+	return config.MinWeightPercent
+}
+
+// 健康检查间隔,默认10秒
+func (config *HealthProbeConfig) GetIntervalSeconds() int {
+	if 0 >= config.IntervalSeconds {
+		return 10
+	}
+	return config.IntervalSeconds
+}
+
+// 单次检查超时时间,默认3秒
+func (config *HealthProbeConfig) GetTimeoutSeconds() int {
+	if 0 >= config.TimeoutSeconds {
+		return 3
+	}
+	return config.TimeoutSeconds
+}
+
+// 同时检查的实例数,默认4
+func (config *HealthProbeConfig) GetConcurrency() int {
+	if 0 >= config.Concurrency {
+		return 4
+	}
+	return config.Concurrency
+}
+
+// 连续失败多少次后标记为不健康,默认2
+func (config *HealthProbeConfig) GetUnhealthyThreshold() int {
+	if 0 >= config.UnhealthyThreshold {
+		return 2
+	}
+	return config.UnhealthyThreshold
+}
+
+// instanceId 冲突时的处理,默认告警
+func (config *Config) GetInstanceIdConflict() string {
+	if isEmpty(config.InstanceConfig.InstanceIdConflict) {
+		return InstanceIdConflictWarn
+	}
+	return config.InstanceConfig.InstanceIdConflict
+}
+
+// actuator 端点的路径前缀,默认 /actuator, 为根路径时返回空串
+func (config *ManagementConfig) GetBasePath() string {
+	if isEmpty(config.BasePath) {
+		return "/actuator"
+	}
+	basePath := strings.Trim(strings.TrimSpace(config.BasePath), "/")
+	if 0 == len(basePath) {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// 实例元数据服务地址,默认 http://169.254.169.254
+func (config *DataCenterInfoConfig) GetMetadataEndpoint() string {
+	if isEmpty(config.MetadataEndpoint) {
+		return DefaultAmazonMetadataEndpoint
+	}
+	return config.MetadataEndpoint
+}
+
+// 获取每项实例元数据的超时时间,默认1000毫秒
+func (config *DataCenterInfoConfig) GetMetadataTimeoutMs() int {
+	if 0 >= config.MetadataTimeoutMs {
+		return 1000
+	}
+	return config.MetadataTimeoutMs
+}
+
+// 0 表示未配置取默认值, 小于0表示关闭
+func intOrDefault(value int, defaultValue int) int {
+	if 0 > value {
+		return 0
+	}
+	if 0 == value {
+		return defaultValue
+	}
+	return value
+}
+
 func substitute(in []byte) ([]byte, error) {
 	t, err := template.New("config").Parse(string(in))
 	if err != nil {
@@ -297,63 +820,3 @@ func substitute(in []byte) ([]byte, error) {
 
 	return buffer.Bytes(), nil
 }
-
-func validate(object interface{}) error {
-	if valid := validateValue(object); valid != nil {
-		return errors.New(fmt.Sprintf("Missing required config field: %v of type %s", valid.Name, valid.Type))
-	}
-	return nil
-}
-
-func validateValue(object interface{}) *inValid {
-	objType := reflect.TypeOf(object)
-	objValue := reflect.ValueOf(object)
-	// If object is a nil interface value, TypeOf returns nil.
-	if objType == nil {
-		// Don't validate nil interfaces
-		return nil
-	}
-
-	switch objType.Kind() {
-	case reflect.Ptr:
-		// If the ptr is nil
-		if objValue.IsNil() {
-			return &inValid{Type: objType.String()}
-		}
-		// De-reference the ptr and pass the object to validate
-		return validateValue(objValue.Elem().Interface())
-	case reflect.Struct:
-		for idx := 0; idx < objValue.NumField(); idx++ {
-			if valid := validateValue(objValue.Field(idx).Interface()); valid != nil {
-				field := objType.Field(idx)
-				// Capture sub struct names
-				if valid.Name != "" {
-					field.Name = field.Name + "." + valid.Name
-				}
-
-				// If our field is a pointer and it's pointing to an object
-				if field.Type.Kind() == reflect.Ptr && !objValue.Field(idx).IsNil() {
-					// The optional doesn't apply because our field does exist
-					// instead the de-referenced object failed validation
-					if field.Tag.Get("config") == "optional" {
-						return &inValid{Name: field.Name, Type: valid.Type}
-					}
-				}
-				// If the field is optional, don't invalidate
-				if field.Tag.Get("config") != "optional" {
-					return &inValid{Name: field.Name, Type: valid.Type}
-				}
-			}
-		}
-	// no way to tell if boolean or integer fields are provided or not
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint8, reflect.Uint16,
-		reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Bool, reflect.Interface,
-		reflect.Func:
-		return nil
-	default:
-		if objValue.Len() == 0 {
-			return &inValid{Type: objType.Name()}
-		}
-	}
-	return nil
-}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	core "github.com/phpdragon/go-eureka-client/core"
+	httpClient "github.com/phpdragon/go-eureka-client/httpclent"
+	"github.com/phpdragon/go-eureka-client/logger"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	//实例元数据服务(IMDS)地址
+	DefaultAmazonMetadataEndpoint = "http://169.254.169.254"
+
+	amazonTokenPath    = "/latest/api/token"
+	amazonMetadataPath = "/latest/meta-data/"
+	amazonTokenHeader  = "X-aws-ec2-metadata-token"
+	//IMDSv2 token 有效期(s)
+	amazonTokenTtlHeader  = "X-aws-ec2-metadata-token-ttl-seconds"
+	amazonTokenTtlSeconds = "21600"
+)
+
+// 构建注册的数据中心信息, 为 Amazon 时从实例元数据服务获取元数据, 配置的元数据优先
+// 获取失败时记录警告并退回 MyOwn, 配置了 failFast 时返回错误
+func newDataCenterInfo(config *DataCenterInfoConfig, log *logger.Logger) (*core.DataCenterInfo, error) {
+	if core.DC_NAME_TYPE_AMAZON != config.Name {
+		return myOwnDataCenterInfo(), nil
+	}
+
+	metadata, err := fetchAmazonMetadata(config.GetMetadataEndpoint(), config.GetMetadataTimeoutMs())
+	if nil != err {
+		if config.FailFast {
+			return nil, err
+		}
+		log.Warn(fmt.Sprintf("%s, fall back to %s data center", err.Error(), core.DC_NAME_TYPE_MY_OWN))
+		return myOwnDataCenterInfo(), nil
+	}
+	config.Metadata.mergeInto(metadata)
+
+	return &core.DataCenterInfo{
+		Class:    "com.netflix.appinfo.AmazonInfo",
+		Name:     core.DC_NAME_TYPE_AMAZON,
+		Metadata: metadata,
+	}, nil
+}
+
+func myOwnDataCenterInfo() *core.DataCenterInfo {
+	return &core.DataCenterInfo{
+		Class: "com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo",
+		Name:  core.DC_NAME_TYPE_MY_OWN,
+	}
+}
+
+// 配置了的元数据覆盖获取到的值
+func (config *DataCenterMetadataConfig) mergeInto(metadata *core.DataCenterMetadata) {
+	fields := []struct {
+		configured string
+		target     *string
+	}{
+		{config.AmiLaunchIndex, &metadata.AmiLaunchIndex},
+		{config.LocalHostname, &metadata.LocalHostname},
+		{config.AvailabilityZone, &metadata.AvailabilityZone},
+		{config.InstanceId, &metadata.InstanceId},
+		{config.PublicIpv4, &metadata.PublicIpv4},
+		{config.PublicHostname, &metadata.PublicHostname},
+		{config.AmiManifestPath, &metadata.AmiManifestPath},
+		{config.LocalIpv4, &metadata.LocalIpv4},
+		{config.Hostname, &metadata.Hostname},
+		{config.AmiID, &metadata.AmiID},
+		{config.InstanceType, &metadata.InstanceType},
+	}
+	for _, field := range fields {
+		if !isEmpty(field.configured) {
+			*field.target = field.configured
+		}
+	}
+}
+
+// 通过 IMDSv2 获取 EC2 实例元数据: 先 PUT 获取 token, 再带 token 逐项查询,
+// 没有公网ip等不存在的项(404)留空, instance-id 必须存在
+func fetchAmazonMetadata(endpoint string, timeoutMs int) (*core.DataCenterMetadata, error) {
+	endpoint = strings.TrimRight(endpoint, "/")
+	client := &http.Client{Timeout: time.Duration(timeoutMs) * time.Millisecond}
+
+	token, err := httpClient.Request(endpoint+amazonTokenPath, http.MethodPut, client).
+		Header(amazonTokenTtlHeader, amazonTokenTtlSeconds).
+		Send().StatusOk().Text()
+	if nil != err {
+		return nil, fmt.Errorf("Failed to get Amazon metadata token from %s: %w", endpoint, err)
+	}
+
+	metadata := &core.DataCenterMetadata{}
+	items := []struct {
+		path   string
+		target *string
+	}{
+		{"ami-launch-index", &metadata.AmiLaunchIndex},
+		{"local-hostname", &metadata.LocalHostname},
+		{"placement/availability-zone", &metadata.AvailabilityZone},
+		{"instance-id", &metadata.InstanceId},
+		{"public-ipv4", &metadata.PublicIpv4},
+		{"public-hostname", &metadata.PublicHostname},
+		{"ami-manifest-path", &metadata.AmiManifestPath},
+		{"local-ipv4", &metadata.LocalIpv4},
+		{"hostname", &metadata.Hostname},
+		{"ami-id", &metadata.AmiID},
+		{"instance-type", &metadata.InstanceType},
+	}
+	for _, item := range items {
+		result := httpClient.Request(endpoint+amazonMetadataPath+item.path, http.MethodGet, client).
+			Header(amazonTokenHeader, token).
+			Send()
+		if nil == result.Err && http.StatusNotFound == result.Resp.StatusCode {
+			_ = result.Resp.Body.Close()
+			continue
+		}
+		value, err := result.StatusOk().Text()
+		if nil != err {
+			return nil, fmt.Errorf("Failed to get Amazon metadata %s from %s: %w", item.path, endpoint, err)
+		}
+		*item.target = strings.TrimSpace(value)
+	}
+
+	if isEmpty(metadata.InstanceId) {
+		return nil, fmt.Errorf("Amazon metadata instance-id not found at %s", endpoint)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/core"
+	"github.com/phpdragon/go-eureka-client/logger"
+)
+
+// 模拟 EC2 实例元数据服务(IMDSv2), 元数据请求必须带有 PUT 获取的 token
+type imdsStub struct {
+	server *httptest.Server
+	// 元数据项, 如 "instance-id", 不存在的项返回 404
+	items map[string]string
+	// 每个请求的响应延迟
+	delay time.Duration
+
+	mutex    sync.Mutex
+	ttl      string
+	requests int
+}
+
+func newImdsStub(t *testing.T, items map[string]string) *imdsStub {
+	stub := &imdsStub{items: items}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.serveHTTP))
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+func (stub *imdsStub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	stub.mutex.Lock()
+	stub.requests++
+	stub.mutex.Unlock()
+	time.Sleep(stub.delay)
+
+	if amazonTokenPath == r.URL.Path {
+		if http.MethodPut != r.Method {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		stub.mutex.Lock()
+		stub.ttl = r.Header.Get(amazonTokenTtlHeader)
+		stub.mutex.Unlock()
+		_, _ = w.Write([]byte("test-token"))
+		return
+	}
+	if "test-token" != r.Header.Get(amazonTokenHeader) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	value, ok := stub.items[strings.TrimPrefix(r.URL.Path, amazonMetadataPath)]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_, _ = w.Write([]byte(value + "\n"))
+}
+
+func amazonItems() map[string]string {
+	return map[string]string{
+		"instance-id":                 "i-0123456789",
+		"placement/availability-zone": "us-east-1a",
+		"local-ipv4":                  "10.0.0.5",
+		"local-hostname":              "ip-10-0-0-5.ec2.internal",
+		"hostname":                    "ip-10-0-0-5.ec2.internal",
+		"ami-id":                      "ami-abc",
+		"instance-type":               "t3.micro",
+	}
+}
+
+// 通过 token 获取元数据, 不存在的项留空, 配置的元数据优先
+func TestAmazonDataCenterInfo(t *testing.T) {
+	stub := newImdsStub(t, amazonItems())
+	config := &DataCenterInfoConfig{Name: core.DC_NAME_TYPE_AMAZON, MetadataEndpoint: stub.server.URL + "/"}
+	config.Metadata.InstanceType = "configured"
+
+	info, err := newDataCenterInfo(config, logger.NewLogAgent(nil))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "com.netflix.appinfo.AmazonInfo" != info.Class || core.DC_NAME_TYPE_AMAZON != info.Name {
+		t.Fatalf("got data center %s %s", info.Class, info.Name)
+	}
+	metadata := info.Metadata
+	if "i-0123456789" != metadata.InstanceId || "us-east-1a" != metadata.AvailabilityZone || "10.0.0.5" != metadata.LocalIpv4 {
+		t.Fatalf("got metadata %+v", metadata)
+	}
+	if 0 != len(metadata.PublicIpv4) || 0 != len(metadata.PublicHostname) {
+		t.Fatalf("got public address %s %s, want empty", metadata.PublicIpv4, metadata.PublicHostname)
+	}
+	if "configured" != metadata.InstanceType {
+		t.Fatalf("got instance type %s, want configured value", metadata.InstanceType)
+	}
+	if amazonTokenTtlSeconds != stub.ttl {
+		t.Fatalf("got token ttl %q", stub.ttl)
+	}
+}
+
+// 不是 Amazon 时不访问实例元数据服务
+func TestDefaultDataCenterInfo(t *testing.T) {
+	stub := newImdsStub(t, amazonItems())
+	info, err := newDataCenterInfo(&DataCenterInfoConfig{MetadataEndpoint: stub.server.URL}, logger.NewLogAgent(nil))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if core.DC_NAME_TYPE_MY_OWN != info.Name || nil != info.Metadata {
+		t.Fatalf("got data center %+v", info)
+	}
+	if 0 != stub.requests {
+		t.Fatalf("got %d metadata requests, want 0", stub.requests)
+	}
+}
+
+// 配置了 failFast 时 instance-id 不存在报错
+func TestAmazonDataCenterInfoRequiresInstanceId(t *testing.T) {
+	items := amazonItems()
+	delete(items, "instance-id")
+	stub := newImdsStub(t, items)
+
+	_, err := newDataCenterInfo(&DataCenterInfoConfig{Name: core.DC_NAME_TYPE_AMAZON, MetadataEndpoint: stub.server.URL, FailFast: true}, logger.NewLogAgent(nil))
+	if nil == err || !strings.Contains(err.Error(), "instance-id not found") {
+		t.Fatalf("got %v, want instance-id error", err)
+	}
+}
+
+// 实例元数据服务响应超时, 配置了 failFast 时报错
+func TestAmazonDataCenterInfoTimeout(t *testing.T) {
+	stub := newImdsStub(t, amazonItems())
+	stub.delay = 200 * time.Millisecond
+
+	start := time.Now()
+	_, err := newDataCenterInfo(&DataCenterInfoConfig{Name: core.DC_NAME_TYPE_AMAZON, MetadataEndpoint: stub.server.URL, MetadataTimeoutMs: 20, FailFast: true}, logger.NewLogAgent(nil))
+	if nil == err || !strings.Contains(err.Error(), "token") {
+		t.Fatalf("got %v, want token timeout", err)
+	}
+	if elapsed := time.Since(start); time.Second < elapsed {
+		t.Fatalf("took %s, want to give up after the timeout", elapsed)
+	}
+}
+
+// 实例元数据服务不可用、返回非200或缺少 instance-id 时退回 MyOwn
+func TestAmazonDataCenterInfoFallback(t *testing.T) {
+	unreachable := newImdsStub(t, amazonItems())
+	unreachable.server.Close()
+	items := amazonItems()
+	delete(items, "instance-id")
+	incomplete := newImdsStub(t, items)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	for _, endpoint := range []string{unreachable.server.URL, incomplete.server.URL, failing.URL} {
+		info, err := newDataCenterInfo(&DataCenterInfoConfig{Name: core.DC_NAME_TYPE_AMAZON, MetadataEndpoint: endpoint, MetadataTimeoutMs: 50}, logger.NewLogAgent(nil))
+		if nil != err {
+			t.Fatal(err)
+		}
+		if core.DC_NAME_TYPE_MY_OWN != info.Name || "com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo" != info.Class || nil != info.Metadata {
+			t.Fatalf("got data center %+v", info)
+		}
+	}
+}
+
+// 从yaml绑定数据中心配置
+func TestLoadDataCenterInfo(t *testing.T) {
+	config := loadConfig(t, "config.yaml", `
+eureka:
+  instance:
+    dataCenterInfo:
+      name: Amazon
+      metadataEndpoint: http://127.0.0.1:1
+      metadataTimeoutMs: 50
+      metadata:
+        availabilityZone: us-east-1b
+`)
+	info := config.InstanceConfig.DataCenterInfo
+	if core.DC_NAME_TYPE_AMAZON != info.Name || "http://127.0.0.1:1" != info.GetMetadataEndpoint() || 50 != info.GetMetadataTimeoutMs() {
+		t.Fatalf("got %+v", info)
+	}
+	if "us-east-1b" != info.Metadata.AvailabilityZone {
+		t.Fatalf("got availability zone %s", info.Metadata.AvailabilityZone)
+	}
+}
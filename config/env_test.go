@@ -0,0 +1,83 @@
+package config
+
+import (
+	"testing"
+)
+
+// 示例配置中 nonSecurePort 引用 server.port, SERVER_PORT 同时决定注册的端口
+func TestSampleConfigServerPort(t *testing.T) {
+	t.Setenv("SERVER_PORT", "9999")
+	config, err := LoadConfig("../config_sample.yaml", false)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 9999 != config.InstanceConfig.NonSecurePort {
+		t.Fatalf("got nonSecurePort %d, want 9999", config.InstanceConfig.NonSecurePort)
+	}
+}
+
+// 环境变量覆盖配置文件中的任意配置项
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv("EUREKA_INSTANCE_APPNAME", "from-env")
+	t.Setenv("EUREKA_INSTANCE_LEASEINFO_DURATIONINSECS", "45")
+	t.Setenv("EUREKA_INSTANCE_METADATA_ZONE", "zone-b")
+	t.Setenv("EUREKA_CLIENTS_DEMO_READTIMEOUTMS", "250")
+	t.Setenv("eureka.client.fetchRegistry", "true")
+	config := loadConfig(t, "config.yaml", `
+eureka:
+  client:
+    fetchRegistry: false
+  clients:
+    demo:
+      readTimeoutMs: 100
+  instance:
+    appName: from-file
+    metadata:
+      zone: zone-a
+      version: 1
+    leaseInfo:
+      durationInSecs: 90
+`)
+	instance := config.InstanceConfig
+	if "from-env" != instance.AppName || 45 != instance.LeaseInfo.DurationInSecs || !config.ClientConfig.FetchRegistry {
+		t.Fatalf("got %+v", config)
+	}
+	if "zone-b" != instance.Metadata["zone"] || 1 != instance.Metadata["version"] {
+		t.Fatalf("got metadata %v", instance.Metadata)
+	}
+	if 250 != config.Clients["demo"].ReadTimeoutMs {
+		t.Fatalf("got clients %+v", config.Clients)
+	}
+}
+
+// SERVER_PORT 只在未显式配置 nonSecurePort 时作为注册的端口
+func TestServerPortPrecedence(t *testing.T) {
+	t.Setenv("SERVER_PORT", "9999")
+	config := loadConfig(t, "config.yaml", `
+eureka:
+  instance:
+    appName: demo
+`)
+	if 9999 != config.InstanceConfig.NonSecurePort {
+		t.Fatalf("got %d, want SERVER_PORT as fallback", config.InstanceConfig.NonSecurePort)
+	}
+
+	config = loadConfig(t, "config.yaml", `
+eureka:
+  instance:
+    nonSecurePort: 8080
+`)
+	if 8080 != config.InstanceConfig.NonSecurePort {
+		t.Fatalf("got %d, want explicit nonSecurePort", config.InstanceConfig.NonSecurePort)
+	}
+
+	t.Setenv("EUREKA_INSTANCE_NONSECUREPORT", "7777")
+	config = loadConfig(t, "config.yaml", `
+eureka:
+  instance:
+    nonSecurePort: 8080
+`)
+	if 7777 != config.InstanceConfig.NonSecurePort {
+		t.Fatalf("got %d, want EUREKA_INSTANCE_NONSECUREPORT", config.InstanceConfig.NonSecurePort)
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"testing"
+)
+
+// 注册ip为 10.0.0.5, 主机名为 demo.local 的配置
+func instanceConfig() *Config {
+	config := validConfig()
+	config.InstanceConfig.IpAddress = "10.0.0.5"
+	config.InstanceConfig.Hostname = "demo.local"
+	return config
+}
+
+func newTestInstance(t *testing.T, config *Config) (homePage, statusPage, healthCheck, secureHealthCheck string) {
+	instance, err := NewInstance(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return instance.HomePageUrl, instance.StatusPageUrl, instance.HealthCheckUrl, instance.SecureHealthCheckUrl
+}
+
+// 只启用http端口时使用http地址, 不注册安全健康检查地址
+func TestInstanceUrlsHttp(t *testing.T) {
+	homePage, statusPage, healthCheck, secureHealthCheck := newTestInstance(t, instanceConfig())
+	if "http://demo.local:8080/" != homePage || "http://demo.local:8080/actuator/info" != statusPage ||
+		"http://demo.local:8080/actuator/health" != healthCheck || 0 != len(secureHealthCheck) {
+		t.Fatalf("got %s %s %s %s", homePage, statusPage, healthCheck, secureHealthCheck)
+	}
+}
+
+// 启用https端口时使用https地址及https端口
+func TestInstanceUrlsHttps(t *testing.T) {
+	config := instanceConfig()
+	config.InstanceConfig.SecurePortEnabled = true
+	config.InstanceConfig.SecurePort = 8443
+	config.InstanceConfig.HealthCheckUrlPath = "/ping"
+
+	homePage, statusPage, healthCheck, secureHealthCheck := newTestInstance(t, config)
+	if "https://demo.local:8443/" != homePage || "https://demo.local:8443/actuator/info" != statusPage {
+		t.Fatalf("got %s %s", homePage, statusPage)
+	}
+	if "https://demo.local:8443/ping" != healthCheck || "https://demo.local:8443/ping" != secureHealthCheck {
+		t.Fatalf("got %s %s", healthCheck, secureHealthCheck)
+	}
+}
+
+// 配置的完整地址优先于相对地址
+func TestInstanceUrlsAbsoluteOverrides(t *testing.T) {
+	config := instanceConfig()
+	config.InstanceConfig.StatusPageUrlPath = "/info"
+	config.InstanceConfig.StatusPageUrl = " http://status.example.com/info "
+	config.InstanceConfig.HealthCheckUrl = "http://health.example.com/health"
+	config.InstanceConfig.SecureHealthCheckUrl = "https://health.example.com/health"
+
+	_, statusPage, healthCheck, secureHealthCheck := newTestInstance(t, config)
+	if "http://status.example.com/info" != statusPage || "http://health.example.com/health" != healthCheck ||
+		"https://health.example.com/health" != secureHealthCheck {
+		t.Fatalf("got %s %s %s", statusPage, healthCheck, secureHealthCheck)
+	}
+}
+
+// 地址中的主机名与注册的ip可以不同, preferIpAddress 时使用ip, ipv6 地址加方括号
+func TestInstanceUrlsHost(t *testing.T) {
+	config := instanceConfig()
+	instance, err := NewInstance(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "demo.local" != instance.HostName || "10.0.0.5" != instance.IpAddr {
+		t.Fatalf("got hostname %s, ip %s", instance.HostName, instance.IpAddr)
+	}
+
+	config.InstanceConfig.PreferIpAddress = true
+	config.InstanceConfig.IpAddress = "2001:db8::5"
+	homePage, _, healthCheck, _ := newTestInstance(t, config)
+	if "http://[2001:db8::5]:8080/" != homePage || "http://[2001:db8::5]:8080/actuator/health" != healthCheck {
+		t.Fatalf("got %s %s", homePage, healthCheck)
+	}
+}
+
+// 独立的管理端口: 状态及健康检查地址使用该端口的http地址, 元数据中添加 management.port
+func TestInstanceUrlsManagementPort(t *testing.T) {
+	config := instanceConfig()
+	config.InstanceConfig.SecurePortEnabled = true
+	config.InstanceConfig.SecurePort = 8443
+	config.InstanceConfig.Management.Port = 9090
+	config.InstanceConfig.Management.BasePath = "/manage"
+
+	instance, err := NewInstance(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "https://demo.local:8443/" != instance.HomePageUrl || "http://demo.local:9090/manage/info" != instance.StatusPageUrl ||
+		"http://demo.local:9090/manage/health" != instance.HealthCheckUrl || 0 != len(instance.SecureHealthCheckUrl) {
+		t.Fatalf("got %s %s %s %s", instance.HomePageUrl, instance.StatusPageUrl, instance.HealthCheckUrl, instance.SecureHealthCheckUrl)
+	}
+	if "9090" != instance.Metadata["management.port"] {
+		t.Fatalf("got metadata %v", instance.Metadata)
+	}
+
+	//与服务端口相同时不是独立的管理端口
+	config.InstanceConfig.Management.Port = 8443
+	if instance, err = NewInstance(config); nil != err {
+		t.Fatal(err)
+	}
+	if "https://demo.local:8443/manage/health" != instance.HealthCheckUrl || nil != instance.Metadata["management.port"] {
+		t.Fatalf("got %s, metadata %v", instance.HealthCheckUrl, instance.Metadata)
+	}
+}
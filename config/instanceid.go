@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	//已有其他主机的实例使用相同的 instanceId 时记录告警日志后继续注册
+	InstanceIdConflictWarn = "warn"
+	//已有其他主机的实例使用相同的 instanceId 时不注册
+	InstanceIdConflictRefuse = "refuse"
+	//不检查 instanceId 是否冲突
+	InstanceIdConflictIgnore = "ignore"
+)
+
+// instanceId 模板中的变量, 如 {appName}:{hostname}:{port}:{random}, 变量名忽略大小写及'-'、'_'
+var instanceIdVariable = regexp.MustCompile(`\{([\w-]+)\}`)
+
+// instanceId 模板变量的取值
+type instanceIdValues struct {
+	appName string
+	ip      string
+	port    int
+	// 按需获取主机名
+	hostname func() (string, error)
+}
+
+// 按模板生成 instanceId, 支持的变量:
+//
+//	{appName}   应用名
+//	{hostname}  主机名, 配置了 eureka.instance.hostname 时取配置值
+//	{ip}        注册的ip地址
+//	{port}      服务端口, 启用https端口时为https端口
+//	{random}    8位随机十六进制字符串, 每次启动不同
+//	{podName}   环境变量 POD_NAME, 不存在时取 HOSTNAME 或主机名, 用于 kubernetes
+func expandInstanceId(template string, values instanceIdValues) (string, error) {
+	var err error
+	instanceId := instanceIdVariable.ReplaceAllStringFunc(template, func(variable string) string {
+		value, e := values.lookup(variable[1 : len(variable)-1])
+		if nil != e && nil == err {
+			err = e
+		}
+		return value
+	})
+	if nil != err {
+		return "", err
+	}
+	return instanceId, nil
+}
+
+func (values instanceIdValues) lookup(name string) (string, error) {
+	switch canonicalName(name) {
+	case "appname":
+		return values.appName, nil
+	case "hostname":
+		return values.hostname()
+	case "ip":
+		return values.ip, nil
+	case "port":
+		return strconv.Itoa(values.port), nil
+	case "random":
+		return randomHex(4), nil
+	case "podname":
+		for _, env := range []string{"POD_NAME", "HOSTNAME"} {
+			if podName := strings.TrimSpace(os.Getenv(env)); 0 < len(podName) {
+				return podName, nil
+			}
+		}
+		return values.hostname()
+	}
+	return "", fmt.Errorf("Unknown instanceId variable {%s}", name)
+}
+
+// 校验 instanceId 模板中的变量名
+func validateInstanceIdTemplate(template string) error {
+	values := instanceIdValues{hostname: func() (string, error) { return "", nil }}
+	for _, match := range instanceIdVariable.FindAllStringSubmatch(template, -1) {
+		if _, err := values.lookup(match[1]); nil != err {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestExpandInstanceId(t *testing.T) {
+	t.Setenv("POD_NAME", "demo-7d9f")
+	values := instanceIdValues{
+		appName:  "demo",
+		ip:       "10.0.0.5",
+		port:     8443,
+		hostname: func() (string, error) { return "demo.local", nil },
+	}
+
+	instanceId, err := expandInstanceId("{appName}:{host-name}:{IP}:{port}:{pod_name}", values)
+	if nil != err || "demo:demo.local:10.0.0.5:8443:demo-7d9f" != instanceId {
+		t.Fatalf("got %s, %v", instanceId, err)
+	}
+
+	random, err := expandInstanceId("{appName}-{random}", values)
+	if nil != err || !regexp.MustCompile(`^demo-[0-9a-f]{8}$`).MatchString(random) {
+		t.Fatalf("got %s, %v", random, err)
+	}
+	if other, _ := expandInstanceId("{appName}-{random}", values); other == random {
+		t.Fatalf("got the same random instanceId %s twice", random)
+	}
+}
+
+// 没有 POD_NAME 时依次取 HOSTNAME 及主机名
+func TestExpandInstanceIdPodNameFallback(t *testing.T) {
+	t.Setenv("POD_NAME", "")
+	t.Setenv("HOSTNAME", "")
+	values := instanceIdValues{hostname: func() (string, error) { return "demo.local", nil }}
+	if instanceId, err := expandInstanceId("{podName}", values); nil != err || "demo.local" != instanceId {
+		t.Fatalf("got %s, %v", instanceId, err)
+	}
+
+	t.Setenv("HOSTNAME", "demo-pod")
+	if instanceId, err := expandInstanceId("{podName}", values); nil != err || "demo-pod" != instanceId {
+		t.Fatalf("got %s, %v", instanceId, err)
+	}
+}
+
+func TestExpandInstanceIdUnknownVariable(t *testing.T) {
+	_, err := expandInstanceId("{appName}:{zone}", instanceIdValues{appName: "demo"})
+	if nil == err || !strings.Contains(err.Error(), "{zone}") {
+		t.Fatalf("got %v, want unknown variable error", err)
+	}
+}
+
+// 配置了模板时按模板生成, 未配置时为 主机名:端口
+func TestNewInstanceId(t *testing.T) {
+	config := instanceConfig()
+	config.InstanceConfig.InstanceId = "{appName}:{ip}:{port}"
+	instance, err := NewInstance(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "demo:10.0.0.5:8080" != instance.InstanceId {
+		t.Fatalf("got %s", instance.InstanceId)
+	}
+
+	config.InstanceConfig.InstanceId = ""
+	if instance, err = NewInstance(config); nil != err {
+		t.Fatal(err)
+	}
+	if "demo.local:8080" != instance.InstanceId {
+		t.Fatalf("got %s", instance.InstanceId)
+	}
+}
+
+func TestGetInstanceIdConflict(t *testing.T) {
+	config := validConfig()
+	if InstanceIdConflictWarn != config.GetInstanceIdConflict() {
+		t.Fatalf("got %s, want warn by default", config.GetInstanceIdConflict())
+	}
+	config.InstanceConfig.InstanceIdConflict = InstanceIdConflictRefuse
+	if InstanceIdConflictRefuse != config.GetInstanceIdConflict() {
+		t.Fatalf("got %s", config.GetInstanceIdConflict())
+	}
+}
@@ -0,0 +1,373 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	yaml "gopkg.in/yaml.v3"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 解析配置文件中 spring 风格的 ${...} 占位符, 如:
+//
+//	${SERVER_PORT:8080}                         环境变量, 不存在时取冒号后的默认值
+//	${server.port}                              配置文件中的其他属性
+//	${spring.cloud.client.ip-address}           本机ip, 与注册的ip地址规则相同, 另有 spring.cloud.client.hostname
+//	${random.value}                             随机值, 另有 random.int、random.uuid, 进程内按所在属性及出现次序固定
+//
+// 查找顺序: 环境变量(原名及 SERVER_PORT 形式) > 配置文件中的属性 > 内置属性 > 默认值
+// 默认值和属性值中可以继续使用占位符
+type placeholderResolver struct {
+	// 配置文件中的属性, key 为规范化后的属性名
+	properties map[string]string
+	// 已解析的配置文件属性
+	resolved map[string]string
+	// 正在解析的配置文件属性, 用于检测循环引用
+	resolving map[string]bool
+	// 正在选择本机ip, 用于忽略引用了本机ip的 ipAddress
+	findingIp bool
+	// 正在解析的属性及其中已解析的随机值个数, 用于固定随机值
+	property string
+	randoms  int
+}
+
+// 已生成的随机值, 重新加载配置时保持不变, 避免被视为配置修改
+// key: 属性名#出现次序:随机值类型
+var randomValues sync.Map
+
+// 解析yaml中所有标量值的占位符, 一次返回所有无法解析的占位符
+func resolvePlaceholders(root *yaml.Node) error {
+	resolver := &placeholderResolver{
+		properties: make(map[string]string),
+		resolved:   make(map[string]string),
+		resolving:  make(map[string]bool),
+	}
+	walkScalars(root, "", func(path string, node *yaml.Node) {
+		resolver.properties[canonicalName(path)] = node.Value
+	})
+
+	var errs []error
+	walkScalars(root, "", func(path string, node *yaml.Node) {
+		if !strings.Contains(node.Value, "${") {
+			return
+		}
+		value, err := resolver.resolveProperty(canonicalName(path), node.Value)
+		if nil != err {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			return
+		}
+		node.Value = value
+		if 0 == node.Style {
+			//非引号字符串按替换后的值重新推断类型, 如端口为数字
+			node.Tag = ""
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// 按属性路径遍历yaml中的标量, 如 eureka.instance.appName、eureka.client.healthProbe.apps[0]
+func walkScalars(node *yaml.Node, path string, fn func(path string, node *yaml.Node)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkScalars(child, path, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if 0 < len(path) {
+				key = path + "." + key
+			}
+			walkScalars(node.Content[i+1], key, fn)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			walkScalars(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case yaml.ScalarNode:
+		fn(path, node)
+	}
+}
+
+// 替换配置文件属性值中的所有占位符
+func (resolver *placeholderResolver) resolveProperty(name string, value string) (string, error) {
+	property, randoms := resolver.property, resolver.randoms
+	resolver.property, resolver.randoms = name, 0
+	defer func() {
+		resolver.property, resolver.randoms = property, randoms
+	}()
+	return resolver.resolve(value)
+}
+
+// 替换字符串中的所有占位符
+func (resolver *placeholderResolver) resolve(value string) (string, error) {
+	var builder strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if 0 > start {
+			builder.WriteString(value)
+			return builder.String(), nil
+		}
+
+		end := closingBrace(value, start+2)
+		if 0 > end {
+			return "", fmt.Errorf("Unclosed placeholder in value \"%s\"", value)
+		}
+
+		resolved, err := resolver.resolvePlaceholder(value[start+2 : end])
+		if nil != err {
+			return "", err
+		}
+		builder.WriteString(value[:start])
+		builder.WriteString(resolved)
+		value = value[end+1:]
+	}
+}
+
+// 解析一个占位符 ${...} 中的内容
+func (resolver *placeholderResolver) resolvePlaceholder(placeholder string) (string, error) {
+	key, defaultValue, hasDefault := placeholder, "", false
+	if index := separatorIndex(placeholder); 0 <= index {
+		key, defaultValue, hasDefault = placeholder[:index], placeholder[index+1:], true
+	}
+
+	key, err := resolver.resolve(strings.TrimSpace(key))
+	if nil != err {
+		return "", err
+	}
+
+	value, ok, err := resolver.lookup(key)
+	if nil != err {
+		return "", err
+	}
+	if ok {
+		return value, nil
+	}
+	if hasDefault {
+		return resolver.resolve(defaultValue)
+	}
+	return "", fmt.Errorf("Could not resolve placeholder '%s'", key)
+}
+
+func (resolver *placeholderResolver) lookup(key string) (string, bool, error) {
+	if value, ok := lookupEnv(key); ok {
+		return value, true, nil
+	}
+
+	name := canonicalName(key)
+	if value, ok := resolver.resolved[name]; ok {
+		return value, true, nil
+	}
+	if raw, ok := resolver.properties[name]; ok {
+		if resolver.resolving[name] {
+			return "", false, fmt.Errorf("Circular placeholder reference '%s'", key)
+		}
+		resolver.resolving[name] = true
+		value, err := resolver.resolveProperty(name, raw)
+		delete(resolver.resolving, name)
+		if nil != err {
+			return "", false, err
+		}
+		resolver.resolved[name] = value
+		return value, true, nil
+	}
+
+	return resolver.builtinProperty(name)
+}
+
+// 内置属性
+func (resolver *placeholderResolver) builtinProperty(name string) (string, bool, error) {
+	switch name {
+	case "spring.cloud.client.ipaddress":
+		ip, err := resolver.localIpAddress()
+		return ip, nil == err, err
+	case "spring.cloud.client.hostname":
+		hostname, err := os.Hostname()
+		return hostname, nil == err, err
+	case "random.value", "random.int", "random.uuid":
+		key := fmt.Sprintf("%s#%d:%s", resolver.property, resolver.randoms, name)
+		resolver.randoms++
+		if value, ok := randomValues.Load(key); ok {
+			return value.(string), true, nil
+		}
+		value, err := newRandom(name)
+		if nil != err {
+			return "", false, err
+		}
+		actual, _ := randomValues.LoadOrStore(key, value)
+		return actual.(string), true, nil
+	}
+	return "", false, nil
+}
+
+func newRandom(name string) (string, error) {
+	switch name {
+	case "random.int":
+		n, err := rand.Int(rand.Reader, big.NewInt(1<<31-1))
+		if nil != err {
+			return "", err
+		}
+		return strconv.FormatInt(n.Int64(), 10), nil
+	case "random.uuid":
+		uuid := randomHex(16)
+		return fmt.Sprintf("%s-%s-4%s-a%s-%s", uuid[:8], uuid[8:12], uuid[13:16], uuid[17:20], uuid[20:]), nil
+	default:
+		return randomHex(16), nil
+	}
+}
+
+// 本机ip, 与注册的ip地址规则相同: 优先取 eureka.instance.ipAddress, 否则按 inetutils 的规则选择,
+// 规则取自环境变量及配置文件中的 eureka.instance.inetutils.* 或 spring.cloud.inetutils.*
+func (resolver *placeholderResolver) localIpAddress() (string, error) {
+	config := &Config{}
+	instance := &config.InstanceConfig
+
+	//ipAddress 引用了本机ip时忽略该配置, 避免循环引用
+	if !resolver.findingIp {
+		resolver.findingIp = true
+		ipAddress, _, err := resolver.lookup("eureka.instance.ipAddress")
+		resolver.findingIp = false
+		if nil != err {
+			return "", err
+		}
+		instance.IpAddress = ipAddress
+	}
+
+	for _, prefix := range []string{"eureka.instance.inetutils.", "spring.cloud.inetutils."} {
+		ignored, err := resolver.listProperty(prefix + "ignoredInterfaces")
+		if nil != err {
+			return "", err
+		}
+		preferred, err := resolver.listProperty(prefix + "preferredNetworks")
+		if nil != err {
+			return "", err
+		}
+		siteLocal, err := resolver.boolProperty(prefix + "useOnlySiteLocalInterfaces")
+		if nil != err {
+			return "", err
+		}
+		ipv6, err := resolver.boolProperty(prefix + "preferIpv6")
+		if nil != err {
+			return "", err
+		}
+		if 0 == len(instance.Inetutils.IgnoredInterfaces) {
+			instance.Inetutils.IgnoredInterfaces = ignored
+		}
+		if 0 == len(instance.Inetutils.PreferredNetworks) {
+			instance.Inetutils.PreferredNetworks = preferred
+		}
+		instance.Inetutils.UseOnlySiteLocalInterfaces = instance.Inetutils.UseOnlySiteLocalInterfaces || siteLocal
+		instance.Inetutils.PreferIpv6 = instance.Inetutils.PreferIpv6 || ipv6
+	}
+
+	return localIpAddress(config)
+}
+
+// 列表属性, 支持逗号分隔的值及 key[0]、key[1] 形式
+func (resolver *placeholderResolver) listProperty(key string) ([]string, error) {
+	value, ok, err := resolver.lookup(key)
+	if nil != err {
+		return nil, err
+	}
+
+	var values []string
+	if ok {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); 0 < len(item) {
+				values = append(values, item)
+			}
+		}
+		return values, nil
+	}
+	for i := 0; ; i++ {
+		value, ok, err := resolver.lookup(fmt.Sprintf("%s[%d]", key, i))
+		if nil != err {
+			return nil, err
+		}
+		if !ok {
+			return values, nil
+		}
+		values = append(values, strings.TrimSpace(value))
+	}
+}
+
+// 布尔属性, 未配置时为false
+func (resolver *placeholderResolver) boolProperty(key string) (bool, error) {
+	value, ok, err := resolver.lookup(key)
+	if nil != err || !ok {
+		return false, err
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if nil != err {
+		return false, fmt.Errorf("Invalid boolean value '%s' of %s", value, key)
+	}
+	return b, nil
+}
+
+func randomHex(size int) string {
+	buf := make([]byte, size)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// 环境变量, 依次查找原名及 spring 的环境变量形式, 如 server.port 对应 SERVER_PORT
+func lookupEnv(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	return os.LookupEnv(envName(key))
+}
+
+// 属性名对应的环境变量名: 去掉'-', '.'和'[]'替换为'_', 转为大写
+// 如 eureka.client.service-url.defaultZone 对应 EUREKA_CLIENT_SERVICEURL_DEFAULTZONE
+func envName(key string) string {
+	key = strings.ReplaceAll(key, "-", "")
+	key = strings.NewReplacer(".", "_", "[", "_", "]", "").Replace(key)
+	return strings.ToUpper(key)
+}
+
+// 规范化的属性名, 忽略大小写及'-'、'_', 如 app-name、appName、app_name 视为同一个属性
+func canonicalName(key string) string {
+	key = strings.NewReplacer("-", "", "_", "").Replace(key)
+	return strings.ToLower(key)
+}
+
+// 与 ${ 匹配的 } 的位置, 支持嵌套
+func closingBrace(value string, from int) int {
+	depth := 0
+	for i := from; i < len(value); i++ {
+		switch {
+		case strings.HasPrefix(value[i:], "${"):
+			depth++
+			i++
+		case '}' == value[i]:
+			if 0 == depth {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// 占位符中属性名与默认值之间的':'的位置, 忽略嵌套占位符中的':'
+func separatorIndex(placeholder string) int {
+	depth := 0
+	for i := 0; i < len(placeholder); i++ {
+		switch {
+		case strings.HasPrefix(placeholder[i:], "${"):
+			depth++
+			i++
+		case '}' == placeholder[i]:
+			depth--
+		case ':' == placeholder[i] && 0 == depth:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	netUtil "github.com/phpdragon/go-eureka-client/netutil"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// 解析yaml中的占位符, 返回解析后的属性
+func resolveYaml(t *testing.T, content string) (map[string]string, *yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &root); nil != err {
+		t.Fatal(err)
+	}
+	err := resolvePlaceholders(&root)
+	values := make(map[string]string)
+	walkScalars(&root, "", func(path string, node *yaml.Node) {
+		values[path] = node.Value
+	})
+	return values, &root, err
+}
+
+func TestPlaceholderEnvAndDefault(t *testing.T) {
+	t.Setenv("SERVER_PORT", "9999")
+	values, _, err := resolveYaml(t, `
+server:
+  port: ${SERVER_PORT:8080}
+  host: ${SERVER_HOST:localhost}
+  address: ${server.host}:${server.port}
+`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "9999" != values["server.port"] || "localhost" != values["server.host"] || "localhost:9999" != values["server.address"] {
+		t.Fatalf("got %v", values)
+	}
+}
+
+// 环境变量优先于配置文件中的属性, 支持 spring 的环境变量形式
+func TestPlaceholderEnvOverridesProperty(t *testing.T) {
+	t.Setenv("SPRING_APPLICATION_NAME", "from-env")
+	values, _, err := resolveYaml(t, `
+spring:
+  application:
+    name: from-file
+eureka:
+  instance:
+    appName: ${spring.application.name}
+`)
+	if nil != err || "from-env" != values["eureka.instance.appName"] {
+		t.Fatalf("got %v, %v", values, err)
+	}
+}
+
+// 属性名忽略大小写及'-'、'_', 默认值中可以嵌套占位符
+func TestPlaceholderRelaxedNamesAndNestedDefault(t *testing.T) {
+	values, _, err := resolveYaml(t, `
+eureka:
+  instance:
+    app-name: demo
+    instanceId: ${eureka.instance.appName}:${MISSING_PORT:${eureka.instance.port:8080}}
+`)
+	if nil != err || "demo:8080" != values["eureka.instance.instanceId"] {
+		t.Fatalf("got %v, %v", values, err)
+	}
+}
+
+// 一次返回所有无法解析的占位符及循环引用
+func TestPlaceholderErrors(t *testing.T) {
+	_, _, err := resolveYaml(t, `
+a: ${b}
+b: ${a}
+c: ${missing.value}
+d: ${unclosed
+`)
+	if nil == err {
+		t.Fatal("want error")
+	}
+	for _, want := range []string{"Circular placeholder reference", "Could not resolve placeholder 'missing.value'", "Unclosed placeholder", "c:", "d:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q does not contain %q", err.Error(), want)
+		}
+	}
+}
+
+// 非引号字符串按替换后的值重新推断类型, 引号字符串保持为字符串
+func TestPlaceholderRetypesPlainScalars(t *testing.T) {
+	_, root, err := resolveYaml(t, `
+port: ${PORT_NOT_SET:8080}
+quoted: "${PORT_NOT_SET:8080}"
+`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Port   interface{} `yaml:"port"`
+		Quoted interface{} `yaml:"quoted"`
+	}
+	if err := root.Decode(&decoded); nil != err {
+		t.Fatal(err)
+	}
+	if 8080 != decoded.Port || "8080" != decoded.Quoted {
+		t.Fatalf("got %#v, %#v", decoded.Port, decoded.Quoted)
+	}
+}
+
+func TestPlaceholderBuiltins(t *testing.T) {
+	values, _, err := resolveYaml(t, `
+uuid: ${random.uuid}
+value: ${random.value}
+int: ${random.int}
+hostname: ${spring.cloud.client.hostname}
+`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-a[0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(values["uuid"]) {
+		t.Fatalf("got uuid %s", values["uuid"])
+	}
+	if 32 != len(values["value"]) || 0 == len(values["int"]) || 0 == len(values["hostname"]) {
+		t.Fatalf("got %v", values)
+	}
+}
+
+// 随机值按所在属性及出现次序固定, 重新加载时不变
+func TestPlaceholderRandomIsStable(t *testing.T) {
+	content := `
+eureka:
+  instance:
+    metadata:
+      token: ${random.value}-${random.value}
+      ref: ${eureka.instance.metadata.token}
+      other: ${random.value}
+`
+	first, _, err := resolveYaml(t, content)
+	if nil != err {
+		t.Fatal(err)
+	}
+	token, ref, other := first["eureka.instance.metadata.token"], first["eureka.instance.metadata.ref"], first["eureka.instance.metadata.other"]
+	if halves := strings.Split(token, "-"); 2 != len(halves) || halves[0] == halves[1] || token != ref || strings.Contains(token, other) {
+		t.Fatalf("got token %s, ref %s, other %s", token, ref, other)
+	}
+
+	second, _, err := resolveYaml(t, content)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for path, value := range first {
+		if value != second[path] {
+			t.Fatalf("%s changed from %s to %s", path, value, second[path])
+		}
+	}
+}
+
+// 本机ip与注册的ip地址相同, 优先取配置的 ipAddress
+func TestPlaceholderIpAddressOverride(t *testing.T) {
+	config := loadConfig(t, "application.yml", `
+spring:
+  application:
+    name: demo
+eureka:
+  client:
+    service-url:
+      defaultZone: http://eureka:8761/eureka/
+  instance:
+    ip-address: 10.1.2.3
+    instance-id: ${spring.cloud.client.ip-address}:8080
+`)
+	instance, err := NewInstance(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "10.1.2.3:8080" != instance.InstanceId || "10.1.2.3" != instance.IpAddr {
+		t.Fatalf("got instanceId %s, ip %s", instance.InstanceId, instance.IpAddr)
+	}
+
+	t.Setenv("EUREKA_INSTANCE_IPADDRESS", "10.9.9.9")
+	values, _, err := resolveYaml(t, `ip: ${spring.cloud.client.ip-address}`)
+	if nil != err || "10.9.9.9" != values["ip"] {
+		t.Fatalf("got %v, %v, want ip from environment", values, err)
+	}
+}
+
+// 按 inetutils 的规则选择本机ip
+func TestPlaceholderIpAddressUsesInetutils(t *testing.T) {
+	for _, content := range []string{`
+spring:
+  cloud:
+    inetutils:
+      preferred-networks:
+        - 198.51.100.0/24
+ip: ${spring.cloud.client.ip-address}
+`, `
+eureka:
+  instance:
+    inetutils:
+      preferredNetworks: 198.51.100.0/24, 198.18.0.0/15
+ip: ${spring.cloud.client.ip-address}
+`} {
+		_, _, err := resolveYaml(t, content)
+		if nil == err || !strings.Contains(err.Error(), "No local ip address matches") {
+			t.Fatalf("got %v, want no matching address", err)
+		}
+	}
+
+	t.Setenv("SPRING_CLOUD_INETUTILS_USEONLYSITELOCALINTERFACES", "maybe")
+	if _, _, err := resolveYaml(t, `ip: ${spring.cloud.client.ip-address}`); nil == err || !strings.Contains(err.Error(), "maybe") {
+		t.Fatalf("got %v, want invalid boolean error", err)
+	}
+}
+
+// ipAddress 引用本机ip时按 inetutils 的规则选择, 不是循环引用, 与默认实例的ip相同
+func TestPlaceholderIpAddressReferencesBuiltin(t *testing.T) {
+	want, err := netUtil.FindLocalIp(netUtil.InetOptions{})
+	if nil != err {
+		t.Skip(err)
+	}
+	values, _, err := resolveYaml(t, `
+eureka:
+  instance:
+    ipAddress: ${spring.cloud.client.ip-address}
+    instanceId: ${spring.cloud.client.ip-address}:8080
+`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if want != values["eureka.instance.ipAddress"] || want+":8080" != values["eureka.instance.instanceId"] {
+		t.Fatalf("got %v, want %s", values, want)
+	}
+	if ip := NewDefaultInstance().IpAddr; want != ip {
+		t.Fatalf("got default instance ip %s, want %s", ip, want)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	cases := map[string]string{
+		"server.port":                           "SERVER_PORT",
+		"eureka.client.service-url.defaultZone": "EUREKA_CLIENT_SERVICEURL_DEFAULTZONE",
+		"eureka.client.healthProbe.apps[0]":     "EUREKA_CLIENT_HEALTHPROBE_APPS_0",
+	}
+	for key, want := range cases {
+		if got := envName(key); want != got {
+			t.Fatalf("envName(%s): got %s, want %s", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	yaml "gopkg.in/yaml.v3"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// spring cloud eureka 的属性名与本库配置的对应关系, from 为规范化后的属性名前缀
+var springAliases = []struct {
+	from string
+	to   string
+}{
+	{from: "eureka.client.serviceurl", to: "eureka.serviceUrl"},
+	{from: "eureka.instance.metadatamap", to: "eureka.instance.metadata"},
+	{from: "eureka.instance.leaserenewalintervalinseconds", to: "eureka.instance.leaseInfo.renewalIntervalInSecs"},
+	{from: "eureka.instance.leaseexpirationdurationinseconds", to: "eureka.instance.leaseInfo.durationInSecs"},
+	{from: "eureka.instance.instanceenabledonit", to: "eureka.instance.instanceEnabledOnInit"},
+	{from: "spring.cloud.inetutils", to: "eureka.instance.inetutils"},
+	{from: "management.server.port", to: "eureka.instance.management.port"},
+	{from: "management.endpoints.web.basepath", to: "eureka.instance.management.basePath"},
+	{from: "management.contextpath", to: "eureka.instance.management.basePath"},
+	{from: "management", to: "eureka.instance.management"},
+}
+
+// 未配置对应的 eureka 属性时使用的 spring boot 属性
+var springFallbacks = []struct {
+	from string
+	to   string
+}{
+	{from: "spring.application.name", to: "eureka.instance.appName"},
+	{from: "server.port", to: "eureka.instance.nonSecurePort"},
+}
+
+// spring cloud 的默认值, 只在未配置该属性且满足 when 时使用, 其他默认值见各 Get 方法及 Config.applyDefaults
+var springDefaults = []struct {
+	to    string
+	value string
+	when  func(bound *yaml.Node) bool
+}{
+	//只启用了https端口且未配置http端口(含server.port)时不默认开启http端口
+	{to: "eureka.instance.nonSecurePortEnabled", value: "true", when: func(bound *yaml.Node) bool {
+		return !boundBool(bound, "eureka.instance.securePortEnabled") || nil != boundProperty(bound, "eureka.instance.nonSecurePort")
+	}},
+}
+
+// 将配置文件中的属性及环境变量按宽松绑定规则转为 AppConfig 的yaml节点树,
+// 同时兼容本库的属性名(如 eureka.serviceUrl.defaultZone)和 spring cloud 的属性名(如 eureka.client.service-url.defaultZone)
+// 不认识的属性被忽略; 优先级见 LoadConfig
+func bindDocument(source *yaml.Node, environ []string) *yaml.Node {
+	type property struct {
+		tokens []string
+		value  *yaml.Node
+	}
+
+	var properties []property
+	walkScalars(source, "", func(path string, node *yaml.Node) {
+		properties = append(properties, property{tokens: propertyTokens(path), value: node})
+	})
+	//环境变量在配置文件之后绑定, 覆盖配置文件中的值
+	for _, env := range environ {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok || 0 == len(name) {
+			continue
+		}
+		properties = append(properties, property{tokens: envTokens(name), value: plainScalar(value)})
+	}
+
+	bound := &yaml.Node{}
+	documentMapping(bound)
+	appType := reflect.TypeOf(AppConfig{})
+	//先绑定 spring boot 的属性, 配置了对应的 eureka 属性时被覆盖
+	for _, p := range properties {
+		for _, fallback := range springFallbacks {
+			if len(p.tokens) != strings.Count(fallback.from, ".")+1 {
+				continue
+			}
+			if tokens, ok := replacePrefix(p.tokens, fallback.from, fallback.to); ok {
+				if steps, ok := resolveBindPath(appType, tokens, "."); ok {
+					bindValue(bound, steps, p.value)
+				}
+			}
+		}
+	}
+	for _, p := range properties {
+		tokens := p.tokens
+		for _, alias := range springAliases {
+			if replaced, ok := replacePrefix(tokens, alias.from, alias.to); ok {
+				tokens = replaced
+				break
+			}
+		}
+		if steps, ok := resolveBindPath(appType, tokens, "."); ok {
+			bindValue(bound, steps, p.value)
+		}
+	}
+	for _, def := range springDefaults {
+		if nil == boundProperty(bound, def.to) && def.when(bound) {
+			if steps, ok := resolveBindPath(appType, strings.Split(def.to, "."), "."); ok {
+				bindValue(bound, steps, plainScalar(def.value))
+			}
+		}
+	}
+	return bound
+}
+
+// 已绑定的非空属性值, 未配置时返回nil, name 为 AppConfig 中的属性名
+func boundProperty(bound *yaml.Node, name string) *yaml.Node {
+	steps, ok := resolveBindPath(reflect.TypeOf(AppConfig{}), strings.Split(name, "."), ".")
+	if !ok {
+		return nil
+	}
+	node := lookupValue(bound, steps)
+	if nil == node || (yaml.ScalarNode == node.Kind && 0 == len(node.Value)) {
+		return nil
+	}
+	return node
+}
+
+// 已绑定的布尔属性, 未配置或不是布尔值时为false
+func boundBool(bound *yaml.Node, name string) bool {
+	enabled := false
+	if node := boundProperty(bound, name); nil != node {
+		_ = node.Decode(&enabled)
+	}
+	return enabled
+}
+
+// 按'.'和'[i]'拆分属性名, 如 eureka.client.healthProbe.apps[0] 拆分为 eureka client healthProbe apps 0
+func propertyTokens(path string) []string {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	return strings.Split(path, ".")
+}
+
+// 环境变量名拆分为属性名, 如 EUREKA_INSTANCE_LEASEINFO_DURATIONINSECS 拆分为 eureka instance leaseinfo durationinsecs,
+// 也支持直接以属性名作为变量名, 如 eureka.instance.appName
+func envTokens(name string) []string {
+	if strings.Contains(name, ".") {
+		return propertyTokens(name)
+	}
+	return strings.Split(strings.ToLower(name), "_")
+}
+
+// 属性名以 from 开头时替换为 to
+func replacePrefix(tokens []string, from string, to string) ([]string, bool) {
+	prefix := strings.Split(from, ".")
+	if len(tokens) < len(prefix) {
+		return nil, false
+	}
+	for i, part := range prefix {
+		if part != canonicalName(tokens[i]) {
+			return nil, false
+		}
+	}
+	return append(strings.Split(to, "."), tokens[len(prefix):]...), true
+}
+
+func isPropertiesFile(configPath string) bool {
+	return ".properties" == strings.ToLower(filepath.Ext(configPath))
+}
+
+// 解析 .properties 文件, 返回以完整属性名为key的yaml映射
+// 支持'='、':'或空白分隔, '#'、'!'注释, 行尾'\'续行及 \t \n \uXXXX 等转义
+func parseProperties(data []byte) (*yaml.Node, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if 0 == len(line) || '#' == line[0] || '!' == line[0] {
+			continue
+		}
+
+		start := lineNo
+		for continued(line) && scanner.Scan() {
+			lineNo++
+			line = line[:len(line)-1] + strings.TrimLeft(scanner.Text(), " \t\f")
+		}
+
+		key, value := splitProperty(line)
+		key, err := unescapeProperty(key)
+		if nil != err {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+		value, err = unescapeProperty(value)
+		if nil != err {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key, Line: start},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value, Line: start})
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, err
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{mapping}}, nil
+}
+
+// 行尾有奇数个'\'时续行
+func continued(line string) bool {
+	count := 0
+	for i := len(line) - 1; 0 <= i && '\\' == line[i]; i-- {
+		count++
+	}
+	return 1 == count%2
+}
+
+// 按第一个未转义的'='、':'或空白拆分属性名和值
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			key := line[:i]
+			value := strings.TrimLeft(line[i:], " \t\f")
+			if 0 < len(value) && ('=' == value[0] || ':' == value[0]) {
+				value = strings.TrimLeft(value[1:], " \t\f")
+			}
+			return key, value
+		}
+	}
+	return line, ""
+}
+
+func unescapeProperty(str string) (string, error) {
+	if !strings.Contains(str, "\\") {
+		return str, nil
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(str); i++ {
+		if '\\' != str[i] || len(str)-1 == i {
+			builder.WriteByte(str[i])
+			continue
+		}
+
+		i++
+		switch str[i] {
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'u':
+			if len(str) < i+5 {
+				return "", fmt.Errorf("Malformed \\uxxxx encoding in \"%s\"", str)
+			}
+			code, err := strconv.ParseUint(str[i+1:i+5], 16, 32)
+			if nil != err {
+				return "", fmt.Errorf("Malformed \\uxxxx encoding in \"%s\"", str)
+			}
+			builder.WriteRune(rune(code))
+			i += 4
+		default:
+			r, size := utf8.DecodeRuneInString(str[i:])
+			builder.WriteRune(r)
+			i += size - 1
+		}
+	}
+	return builder.String(), nil
+}
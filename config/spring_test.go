@@ -0,0 +1,169 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func writeConfig(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); nil != err {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadConfig(t *testing.T, name string, content string) *Config {
+	config, err := LoadConfig(writeConfig(t, name, content), false)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// 兼容 spring cloud eureka 的 application.yml
+func TestLoadSpringYaml(t *testing.T) {
+	config := loadConfig(t, "application.yml", `
+spring:
+  application:
+    name: demo-service
+server:
+  port: 8081
+eureka:
+  client:
+    service-url:
+      defaultZone: http://eureka:8761/eureka/
+    fetch-registry: true
+    health-probe:
+      apps: [demo, other]
+  instance:
+    prefer-ip-address: true
+    lease-renewal-interval-in-seconds: 5
+    lease-expiration-duration-in-seconds: 15
+    metadata-map:
+      zone: zone-a
+      version: 2
+management:
+  server:
+    port: 9090
+  endpoints:
+    web:
+      base-path: /manage
+unknown:
+  property: ignored
+`)
+	instance := config.InstanceConfig
+	if "http://eureka:8761/eureka/" != config.ServiceURL.DefaultZone || !config.ClientConfig.FetchRegistry {
+		t.Fatalf("client config not bound: %+v", config)
+	}
+	if "demo-service" != instance.AppName || 8081 != instance.NonSecurePort || !instance.NonSecurePortEnabled || !instance.PreferIpAddress {
+		t.Fatalf("instance config not bound: %+v", instance)
+	}
+	if 5 != instance.LeaseInfo.RenewalIntervalInSecs || 15 != instance.LeaseInfo.DurationInSecs {
+		t.Fatalf("got leaseInfo %+v", instance.LeaseInfo)
+	}
+	if "zone-a" != instance.Metadata["zone"] || 2 != instance.Metadata["version"] {
+		t.Fatalf("got metadata %v", instance.Metadata)
+	}
+	if !reflect.DeepEqual([]string{"demo", "other"}, config.ClientConfig.HealthProbe.Apps) {
+		t.Fatalf("got apps %v", config.ClientConfig.HealthProbe.Apps)
+	}
+	if 9090 != instance.Management.Port || "/manage" != instance.Management.BasePath {
+		t.Fatalf("got management %+v", instance.Management)
+	}
+}
+
+// 显式配置的 eureka 属性优先于 spring boot 属性
+func TestEurekaPropertiesOverrideSpringFallbacks(t *testing.T) {
+	config := loadConfig(t, "application.yml", `
+spring.application.name: from-spring
+eureka:
+  instance:
+    appName: from-eureka
+`)
+	if "from-eureka" != config.InstanceConfig.AppName {
+		t.Fatalf("got %s, want from-eureka", config.InstanceConfig.AppName)
+	}
+}
+
+func TestLoadProperties(t *testing.T) {
+	config := loadConfig(t, "application.properties", `
+# spring cloud 风格
+! 另一种注释
+spring.application.name = demo-service
+server.port: 8082
+eureka.client.serviceUrl.defaultZone http://eureka:8761/eureka/
+eureka.client.health-probe.apps[0]=demo
+eureka.client.health-probe.apps[1]=other
+eureka.instance.metadata-map.description=line one \
+    line two
+eureka.instance.metadata-map.greeting=\u4f60\u597d\tworld
+eureka.instance.metadata-map.path=C:\\data
+`)
+	instance := config.InstanceConfig
+	if "demo-service" != instance.AppName || 8082 != instance.NonSecurePort || "http://eureka:8761/eureka/" != config.ServiceURL.DefaultZone {
+		t.Fatalf("got %+v", config)
+	}
+	if !reflect.DeepEqual([]string{"demo", "other"}, config.ClientConfig.HealthProbe.Apps) {
+		t.Fatalf("got apps %v", config.ClientConfig.HealthProbe.Apps)
+	}
+	want := map[string]interface{}{"description": "line one line two", "greeting": "你好\tworld", "path": `C:\data`}
+	if !reflect.DeepEqual(want, instance.Metadata) {
+		t.Fatalf("got metadata %#v", instance.Metadata)
+	}
+}
+
+func TestParsePropertiesErrors(t *testing.T) {
+	if _, err := parseProperties([]byte("a=1\nb=\\u12\n")); nil == err {
+		t.Fatal("want error for malformed unicode escape")
+	}
+}
+
+func TestSplitProperty(t *testing.T) {
+	cases := map[string][2]string{
+		"a=b":         {"a", "b"},
+		"a : b":       {"a", "b"},
+		"a b":         {"a", "b"},
+		`a\=b=c`:      {`a\=b`, "c"},
+		"key":         {"key", ""},
+		"a = b = c":   {"a", "b = c"},
+		"url=http://": {"url", "http://"},
+	}
+	for line, want := range cases {
+		if key, value := splitProperty(line); want[0] != key || want[1] != value {
+			t.Fatalf("splitProperty(%q): got %q, %q", line, key, value)
+		}
+	}
+}
+
+// 属性名按宽松绑定规则对应到配置项, 不认识的属性被忽略
+func TestBindDocumentRelaxedNames(t *testing.T) {
+	var source yaml.Node
+	content := `
+eureka:
+  instance:
+    APP_NAME: relaxed
+    non-secure-port: 8083
+  clients:
+    Demo:
+      read-timeout-ms: 100
+  no-such-field: 1
+`
+	if err := yaml.Unmarshal([]byte(content), &source); nil != err {
+		t.Fatal(err)
+	}
+	config := &AppConfig{}
+	if err := bindDocument(&source, nil).Decode(config); nil != err {
+		t.Fatal(err)
+	}
+	if "relaxed" != config.Eureka.InstanceConfig.AppName || 8083 != config.Eureka.InstanceConfig.NonSecurePort {
+		t.Fatalf("got %+v", config.Eureka.InstanceConfig)
+	}
+	if 100 != config.Eureka.Clients["Demo"].ReadTimeoutMs {
+		t.Fatalf("got clients %+v", config.Eureka.Clients)
+	}
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	core "github.com/phpdragon/go-eureka-client/core"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	//续约间隔默认值，同 spring cloud eureka.instance.lease-renewal-interval-in-seconds
+	DefaultLeaseRenewalIntervalInSecs = 30
+	//租约过期时间默认值，同 spring cloud eureka.instance.lease-expiration-duration-in-seconds
+	DefaultLeaseDurationInSecs = 90
+	//获取注册表的间隔默认值，同 spring cloud eureka.client.registry-fetch-interval-seconds
+	DefaultRegistryFetchIntervalSeconds = 30
+)
+
+// Validate 校验配置, 一次返回所有问题, 不修改配置
+func (config *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if isEmpty(config.ServiceURL.DefaultZone) {
+		invalid("eureka.serviceUrl.defaultZone is empty")
+	}
+	for _, serviceUrl := range strings.Split(config.ServiceURL.DefaultZone, ",") {
+		if serviceUrl = strings.TrimSpace(serviceUrl); 0 == len(serviceUrl) {
+			continue
+		}
+		if err := validateServiceUrl(serviceUrl); nil != err {
+			invalid("eureka.serviceUrl.defaultZone: %s", err.Error())
+		}
+	}
+
+	instance := &config.InstanceConfig
+	if isEmpty(instance.AppName) {
+		invalid("eureka.instance.appName is empty")
+	}
+	if err := validateInstanceIdTemplate(instance.InstanceId); nil != err {
+		invalid("eureka.instance.instanceId %s: %s", instance.InstanceId, err.Error())
+	}
+	switch config.GetInstanceIdConflict() {
+	case InstanceIdConflictWarn, InstanceIdConflictRefuse, InstanceIdConflictIgnore:
+	default:
+		invalid("eureka.instance.instanceIdConflict %s is unknown", instance.InstanceIdConflict)
+	}
+	if !instance.NonSecurePortEnabled && !instance.SecurePortEnabled {
+		invalid("eureka.instance.nonSecurePortEnabled and securePortEnabled are both false")
+	}
+	if instance.NonSecurePortEnabled && !validPort(instance.NonSecurePort) {
+		invalid("eureka.instance.nonSecurePort %d is out of range 1-65535", instance.NonSecurePort)
+	}
+	if instance.SecurePortEnabled && !validPort(instance.SecurePort) {
+		invalid("eureka.instance.securePort %d is out of range 1-65535", instance.SecurePort)
+	}
+	if name := instance.DataCenterInfo.Name; !isEmpty(name) && core.DC_NAME_TYPE_MY_OWN != name && core.DC_NAME_TYPE_AMAZON != name {
+		invalid("eureka.instance.dataCenterInfo.name %s is unknown, expected %s or %s", name, core.DC_NAME_TYPE_MY_OWN, core.DC_NAME_TYPE_AMAZON)
+	}
+	instanceUrls := []struct {
+		name  string
+		value string
+	}{
+		{"homePageUrl", instance.HomePageUrl},
+		{"statusPageUrl", instance.StatusPageUrl},
+		{"healthCheckUrl", instance.HealthCheckUrl},
+		{"secureHealthCheckUrl", instance.SecureHealthCheckUrl},
+	}
+	for _, instanceUrl := range instanceUrls {
+		if isEmpty(instanceUrl.value) {
+			continue
+		}
+		if err := validateServiceUrl(strings.TrimSpace(instanceUrl.value)); nil != err {
+			invalid("eureka.instance.%s: %s", instanceUrl.name, err.Error())
+		}
+	}
+	if !isEmpty(instance.IpAddress) && nil == net.ParseIP(strings.TrimSpace(instance.IpAddress)) {
+		invalid("eureka.instance.ipAddress %s is not an ip address", instance.IpAddress)
+	}
+	for _, pattern := range instance.Inetutils.IgnoredInterfaces {
+		if _, err := regexp.Compile(pattern); nil != err {
+			invalid("eureka.instance.inetutils.ignoredInterfaces %s: %s", pattern, err.Error())
+		}
+	}
+	if 0 != instance.Management.Port && !validPort(instance.Management.Port) {
+		invalid("eureka.instance.management.port %d is out of range 1-65535", instance.Management.Port)
+	}
+	if 0 > instance.LeaseInfo.RenewalIntervalInSecs {
+		invalid("eureka.instance.leaseInfo.renewalIntervalInSecs %d must be positive", instance.LeaseInfo.RenewalIntervalInSecs)
+	}
+	if instance.LeaseInfo.DurationInSecs < instance.LeaseInfo.RenewalIntervalInSecs {
+		invalid("eureka.instance.leaseInfo.durationInSecs %d is less than renewalIntervalInSecs %d, the instance would expire between renewals",
+			instance.LeaseInfo.DurationInSecs, instance.LeaseInfo.RenewalIntervalInSecs)
+	}
+
+	client := &config.ClientConfig
+	if 0 > client.RegistryFetchIntervalSeconds {
+		invalid("eureka.client.registryFetchIntervalSeconds %d must be positive", client.RegistryFetchIntervalSeconds)
+	}
+	if !isLoadBalancer(client.GetLoadBalancer()) {
+		invalid("eureka.client.loadBalancer %s is unknown", client.LoadBalancer)
+	}
+	if curve := client.Warmup.GetCurve(); WarmupCurveLinear != curve && WarmupCurveExponential != curve {
+		invalid("eureka.client.warmup.curve %s is unknown", curve)
+	}
+	if 100 < client.Warmup.MinWeightPercent {
+		invalid("eureka.client.warmup.minWeightPercent %d is greater than 100", client.Warmup.MinWeightPercent)
+	}
+
+	for appId, policy := range config.Clients {
+		if scheme := policy.GetScheme(); SchemeHttp != scheme && SchemeHttps != scheme {
+			invalid("eureka.clients.%s.scheme %s is unknown", appId, scheme)
+		}
+		if !isEmpty(policy.LoadBalancer) && !isLoadBalancer(policy.LoadBalancer) {
+			invalid("eureka.clients.%s.loadBalancer %s is unknown", appId, policy.LoadBalancer)
+		}
+		if 0 > policy.HedgeDelayPercentile || 100 < policy.HedgeDelayPercentile {
+			invalid("eureka.clients.%s.hedgeDelayPercentile %v is out of range 0-100", appId, policy.HedgeDelayPercentile)
+		}
+		if 0 > policy.RateLimitPerSecond {
+			invalid("eureka.clients.%s.rateLimitPerSecond %v must not be negative", appId, policy.RateLimitPerSecond)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// 未配置的续约间隔、租约过期时间、获取注册表间隔取 spring cloud 的默认值, 加载配置时调用
+func (config *Config) applyDefaults() {
+	lease := &config.InstanceConfig.LeaseInfo
+	if 0 == lease.RenewalIntervalInSecs {
+		lease.RenewalIntervalInSecs = DefaultLeaseRenewalIntervalInSecs
+	}
+	if 0 == lease.DurationInSecs {
+		lease.DurationInSecs = DefaultLeaseDurationInSecs
+	}
+	if 0 == config.ClientConfig.RegistryFetchIntervalSeconds {
+		config.ClientConfig.RegistryFetchIntervalSeconds = DefaultRegistryFetchIntervalSeconds
+	}
+}
+
+func validateServiceUrl(serviceUrl string) error {
+	parsed, err := url.Parse(serviceUrl)
+	if nil != err {
+		return err
+	}
+	if ("http" != parsed.Scheme && "https" != parsed.Scheme) || isEmpty(parsed.Host) {
+		return fmt.Errorf("%s is not an absolute http(s) url", serviceUrl)
+	}
+	return nil
+}
+
+func validPort(port int) bool {
+	return 0 < port && 65535 >= port
+}
+
+func isLoadBalancer(name string) bool {
+	switch name {
+	case LoadBalancerRoundRobin, LoadBalancerRandom, LoadBalancerPeakEwma:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func validConfig() *Config {
+	config := &Config{}
+	config.ServiceURL.DefaultZone = "http://eureka1:8761/eureka/, http://eureka2:8761/eureka/"
+	config.InstanceConfig.AppName = "demo"
+	config.InstanceConfig.NonSecurePort = 8080
+	config.InstanceConfig.NonSecurePortEnabled = true
+	return config
+}
+
+// 加载配置时补全 spring cloud 的默认值, 不校验时同样补全
+func TestLoadConfigAppliesDefaults(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "eureka:\n  instance:\n    appName: demo\n")
+	config, err := LoadConfig(path, false)
+	if nil != err {
+		t.Fatal(err)
+	}
+	lease := config.InstanceConfig.LeaseInfo
+	if DefaultLeaseRenewalIntervalInSecs != lease.RenewalIntervalInSecs || DefaultLeaseDurationInSecs != lease.DurationInSecs {
+		t.Fatalf("got leaseInfo %+v", lease)
+	}
+	if DefaultRegistryFetchIntervalSeconds != config.ClientConfig.RegistryFetchIntervalSeconds {
+		t.Fatalf("got registryFetchIntervalSeconds %d", config.ClientConfig.RegistryFetchIntervalSeconds)
+	}
+}
+
+// 一次返回所有问题
+func TestValidateReportsAllErrors(t *testing.T) {
+	config := &Config{}
+	config.ServiceURL.DefaultZone = "eureka:8761"
+	config.InstanceConfig.InstanceId = "{appName}:{nope}"
+	config.InstanceConfig.InstanceIdConflict = "panic"
+	config.InstanceConfig.IpAddress = "not-an-ip"
+	config.InstanceConfig.Inetutils.IgnoredInterfaces = []string{"veth("}
+	config.InstanceConfig.Management.Port = 70000
+	config.InstanceConfig.LeaseInfo.RenewalIntervalInSecs = 30
+	config.InstanceConfig.LeaseInfo.DurationInSecs = 10
+	config.InstanceConfig.DataCenterInfo.Name = "Azure"
+	config.InstanceConfig.HealthCheckUrl = "/health"
+	config.ClientConfig.LoadBalancer = "leastConn"
+	config.ClientConfig.Warmup.Curve = "cubic"
+	config.Clients = map[string]ClientPolicy{"demo": {Scheme: "ftp", LoadBalancer: "fastest", HedgeDelayPercentile: 120, RateLimitPerSecond: -1}}
+
+	err := config.Validate()
+	if nil == err {
+		t.Fatal("want error")
+	}
+	for _, want := range []string{
+		"eureka.serviceUrl.defaultZone: eureka:8761 is not an absolute http(s) url",
+		"eureka.instance.appName is empty",
+		"Unknown instanceId variable {nope}",
+		"eureka.instance.instanceIdConflict panic is unknown",
+		"nonSecurePortEnabled and securePortEnabled are both false",
+		"eureka.instance.ipAddress not-an-ip is not an ip address",
+		"eureka.instance.inetutils.ignoredInterfaces veth(",
+		"eureka.instance.management.port 70000 is out of range",
+		"durationInSecs 10 is less than renewalIntervalInSecs 30",
+		"eureka.instance.dataCenterInfo.name Azure is unknown",
+		"eureka.instance.healthCheckUrl: /health is not an absolute http(s) url",
+		"eureka.client.loadBalancer leastConn is unknown",
+		"eureka.client.warmup.curve cubic is unknown",
+		"eureka.clients.demo.scheme ftp is unknown",
+		"eureka.clients.demo.loadBalancer fastest is unknown",
+		"eureka.clients.demo.hedgeDelayPercentile 120 is out of range",
+		"eureka.clients.demo.rateLimitPerSecond -1 must not be negative",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%s", want, err.Error())
+		}
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	config := validConfig()
+	config.InstanceConfig.NonSecurePort = 0
+	config.InstanceConfig.SecurePortEnabled = true
+	config.InstanceConfig.SecurePort = 65536
+	err := config.Validate()
+	if nil == err || !strings.Contains(err.Error(), "nonSecurePort 0 is out of range") || !strings.Contains(err.Error(), "securePort 65536 is out of range") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+// 只启用https端口时不默认开启http端口
+func TestLoadConfigHttpsOnly(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+eureka:
+  serviceUrl:
+    defaultZone: http://eureka:8761/eureka/
+  instance:
+    appName: demo
+    securePortEnabled: true
+    securePort: 8443
+`)
+	config, err := LoadConfig(path, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if config.InstanceConfig.NonSecurePortEnabled || !config.InstanceConfig.SecurePortEnabled {
+		t.Fatalf("got nonSecurePortEnabled %v, securePortEnabled %v", config.InstanceConfig.NonSecurePortEnabled, config.InstanceConfig.SecurePortEnabled)
+	}
+
+	//配置了http端口时仍默认开启
+	path = writeConfig(t, "application.yml", `
+server:
+  port: 8080
+eureka:
+  serviceUrl:
+    defaultZone: http://eureka:8761/eureka/
+  instance:
+    appName: demo
+    securePortEnabled: true
+    securePort: 8443
+`)
+	if config, err = LoadConfig(path, true); nil != err {
+		t.Fatal(err)
+	}
+	if !config.InstanceConfig.NonSecurePortEnabled || 8080 != config.InstanceConfig.NonSecurePort {
+		t.Fatalf("got nonSecurePortEnabled %v, nonSecurePort %d", config.InstanceConfig.NonSecurePortEnabled, config.InstanceConfig.NonSecurePort)
+	}
+}
+
+// 加载时校验失败返回错误
+func TestLoadConfigValidates(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "eureka:\n  instance:\n    appName: demo\n")
+	if _, err := LoadConfig(path, true); nil == err || !strings.Contains(err.Error(), "eureka.serviceUrl.defaultZone is empty") {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -1,12 +1,17 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	httpClient "github.com/phpdragon/go-eureka-client/httpclent"
+	"net/http"
 	"net/url"
 	"strings"
 )
 
+// ErrNotFound 查询的应用、实例或vip在eureka服务端不存在(404)
+var ErrNotFound = errors.New("not found")
+
 // wiki: https://github.com/Netflix/eureka/wiki/Eureka-REST-operations
 type EurekaServerApi struct {
 	BaseUrl string
@@ -22,6 +27,16 @@ func (api *EurekaServerApi) url(path string) string {
 	return strings.TrimRight(api.BaseUrl, "/") + path
 }
 
+// 发送查询请求并解析json, 404 时返回 ErrNotFound
+func getJson(eurekaUrl string, v interface{}) error {
+	result := httpClient.Get(eurekaUrl).Header("Accept", " application/json").Send()
+	if nil == result.Err && http.StatusNotFound == result.Resp.StatusCode {
+		_ = result.Resp.Body.Close()
+		return ErrNotFound
+	}
+	return result.StatusOk().Json(v)
+}
+
 // Register new application instance by brief info
 func (api *EurekaServerApi) RegisterInstance(appId string, instance *Instance) error {
 	eurekaUrl := api.url("/apps/" + strings.ToUpper(appId))
@@ -54,15 +69,14 @@ func (api *EurekaServerApi) UpdateInstanceStatus(appId, instanceId, status strin
 // 更新实例的元数据
 // Update metadata
 func (api *EurekaServerApi) UpdateMeta(appId, instanceId string, metadata map[string]string) error {
-	queryStr := ""
+	eurekaUrl := api.url("/apps/" + strings.ToUpper(appId) + "/" + instanceId + "/metadata")
+	params := url.Values{}
 	for k, v := range metadata {
-		queryStr += fmt.Sprintf("&%s=%s", k, v)
+		params.Set(k, v)
 	}
-	queryStr = strings.TrimLeft(queryStr, "&")
 
-	eurekaUrl := api.url(fmt.Sprintf("/apps/%s/%s/metadata?%s", appId, instanceId, queryStr))
 	// status: httpClient.StatusNoContent
-	result := httpClient.Put(eurekaUrl).Send().StatusOk()
+	result := httpClient.Put(eurekaUrl).Params(params).Send().StatusOk()
 	if result.Err != nil {
 		return fmt.Errorf("Failed to update instance metadata, err=%s", result.Err)
 	}
@@ -117,9 +131,9 @@ func (api *EurekaServerApi) QueryAllInstances() (*Applications, error) {
 func (api *EurekaServerApi) QueryAllInstanceByAppId(appId string) (*Application, error) {
 	eurekaUrl := api.url("/apps/" + strings.ToUpper(appId))
 	res := &EurekaApp{}
-	err := httpClient.Get(eurekaUrl).Header("Accept", " application/json").Send().StatusOk().Json(&res)
+	err := getJson(eurekaUrl, &res)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to query appId instances, err=%s", err.Error())
+		return nil, fmt.Errorf("Failed to query appId instances, err=%w", err)
 	}
 	return &res.Application, nil
 }
@@ -141,9 +155,9 @@ func (api *EurekaServerApi) QueryAllInstancesByVipAddress(vipAddress string) (*A
 	eurekaUrl := api.url("/vips/" + vipAddress)
 	res := &EurekaApps{}
 
-	err := httpClient.Get(eurekaUrl).Header("Accept", " application/json").Send().StatusOk().Json(&res)
+	err := getJson(eurekaUrl, &res)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to query appId instances, err=%s", err.Error())
+		return nil, fmt.Errorf("Failed to query appId instances, err=%w", err)
 	}
 	return &res.Applications, nil
 }
@@ -152,9 +166,9 @@ func (api *EurekaServerApi) QueryAllInstancesByVipAddress(vipAddress string) (*A
 func (api *EurekaServerApi) QueryAllInstancesBySvipAddress(svipAddress string) (*Applications, error) {
 	eurekaUrl := api.url("/svips/" + svipAddress)
 	res := &EurekaApps{}
-	err := httpClient.Get(eurekaUrl).Header("Accept", " application/json").Send().StatusOk().Json(&res)
+	err := getJson(eurekaUrl, &res)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to query appId instances, err=%s", err.Error())
+		return nil, fmt.Errorf("Failed to query appId instances, err=%w", err)
 	}
 	return &res.Applications, nil
 }
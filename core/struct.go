@@ -40,20 +40,21 @@ type (
 	// InstanceConfig 服务实例
 	Instance struct {
 		// Register application instance needed -- BEGIN
-		InstanceId       string          `json:"instanceId,omitempty"`
-		HostName         string          `json:"hostName"`
-		App              string          `json:"app"`
-		IpAddr           string          `json:"ipAddr"`
-		Status           string          `json:"status"`
-		VipAddress       string          `json:"vipAddress"`
-		SecureVipAddress string          `json:"secureVipAddress,omitempty"`
-		Port             *Port           `json:"port,omitempty"`
-		SecurePort       *Port           `json:"securePort,omitempty"`
-		HomePageUrl      string          `json:"homePageUrl,omitempty"`
-		StatusPageUrl    string          `json:"statusPageUrl"`
-		HealthCheckUrl   string          `json:"healthCheckUrl,omitempty"`
-		DataCenterInfo   *DataCenterInfo `json:"dataCenterInfo"`
-		LeaseInfo        *LeaseInfo      `json:"leaseInfo,omitempty"`
+		InstanceId           string          `json:"instanceId,omitempty"`
+		HostName             string          `json:"hostName"`
+		App                  string          `json:"app"`
+		IpAddr               string          `json:"ipAddr"`
+		Status               string          `json:"status"`
+		VipAddress           string          `json:"vipAddress"`
+		SecureVipAddress     string          `json:"secureVipAddress,omitempty"`
+		Port                 *Port           `json:"port,omitempty"`
+		SecurePort           *Port           `json:"securePort,omitempty"`
+		HomePageUrl          string          `json:"homePageUrl,omitempty"`
+		StatusPageUrl        string          `json:"statusPageUrl"`
+		HealthCheckUrl       string          `json:"healthCheckUrl,omitempty"`
+		SecureHealthCheckUrl string          `json:"secureHealthCheckUrl,omitempty"`
+		DataCenterInfo       *DataCenterInfo `json:"dataCenterInfo"`
+		LeaseInfo            *LeaseInfo      `json:"leaseInfo,omitempty"`
 		// Register application instance needed -- END
 
 		OverriddenStatus              string                 `json:"overriddenstatus,omitempty"`
@@ -103,3 +104,55 @@ type (
 		ServiceUpTimestamp    int64 `json:"serviceUpTimestamp,omitempty"`
 	}
 )
+
+// Clone 深拷贝实例, 修改副本不会影响原实例
+func (instance *Instance) Clone() *Instance {
+	if nil == instance {
+		return nil
+	}
+
+	dst := *instance
+	if nil != instance.Port {
+		port := *instance.Port
+		dst.Port = &port
+	}
+	if nil != instance.SecurePort {
+		securePort := *instance.SecurePort
+		dst.SecurePort = &securePort
+	}
+	if nil != instance.DataCenterInfo {
+		dataCenterInfo := *instance.DataCenterInfo
+		if nil != instance.DataCenterInfo.Metadata {
+			metadata := *instance.DataCenterInfo.Metadata
+			dataCenterInfo.Metadata = &metadata
+		}
+		dst.DataCenterInfo = &dataCenterInfo
+	}
+	if nil != instance.LeaseInfo {
+		leaseInfo := *instance.LeaseInfo
+		dst.LeaseInfo = &leaseInfo
+	}
+	if nil != instance.Metadata {
+		dst.Metadata = make(map[string]interface{}, len(instance.Metadata))
+		for k, v := range instance.Metadata {
+			dst.Metadata[k] = v
+		}
+	}
+	return &dst
+}
+
+// Clone 深拷贝应用及其实例列表
+func (app *Application) Clone() *Application {
+	if nil == app {
+		return nil
+	}
+
+	dst := &Application{
+		Name:      app.Name,
+		Instances: make([]Instance, len(app.Instances)),
+	}
+	for i := range app.Instances {
+		dst.Instances[i] = *app.Instances[i].Clone()
+	}
+	return dst
+}
@@ -0,0 +1,119 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/core"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultDialPortMetadataKey 实例元数据中的TCP端口, 服务注册的端口与TCP服务端口不同时使用, 如 metadata.tcpPort: 6379
+const DefaultDialPortMetadataKey = "tcpPort"
+
+// Dialer 通过注册中心建立TCP连接, DialContext 与 net.Dialer.DialContext 签名一致,
+// 可直接用于 redis、grpc 等客户端的自定义拨号函数
+// 地址为应用名(可带端口, 端口会被忽略), 按负载均衡策略选择实例, 连接失败时换一个实例,
+// 最多换 maxAutoRetriesNextServer 次
+type Dialer struct {
+	client *Client
+
+	// 实际建立连接的 Dialer, 为nil时按应用的 connectTimeoutMs 创建
+	Dialer *net.Dialer
+
+	// 实例元数据中的端口, 为空时使用 DefaultDialPortMetadataKey;
+	// 元数据中没有该端口时依次使用实例的 port、securePort
+	PortMetadataKey string
+}
+
+// NewDialer 创建通过注册中心建立TCP连接的 Dialer, dialer 为nil时按应用的 connectTimeoutMs 创建
+func (client *Client) NewDialer(dialer *net.Dialer) *Dialer {
+	return &Dialer{client: client, Dialer: dialer}
+}
+
+// DialContext 连接应用的一个实例, address 为应用名, 如 DEMO-SERVICE
+func (client *Client) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	return client.NewDialer(nil).DialContext(ctx, network, address)
+}
+
+// Dial 连接应用的一个实例, 同 DialContext
+func (dialer *Dialer) Dial(network string, address string) (net.Conn, error) {
+	return dialer.DialContext(context.Background(), network, address)
+}
+
+// DialContext 连接应用的一个实例, address 为应用名, 如 DEMO-SERVICE 或 DEMO-SERVICE:6379
+func (dialer *Dialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	appId := address
+	if host, _, err := net.SplitHostPort(address); nil == err {
+		appId = host
+	}
+
+	policy := dialer.client.policy(appId)
+	netDialer := dialer.netDialer(policy)
+	picker := dialer.client.newPicker(appId, anyKey)
+
+	var lastErr error
+	for next := 0; next <= policy.config.GetMaxAutoRetriesNextServer(); next++ {
+		instance, err := picker.Next()
+		if nil != err {
+			if nil == lastErr {
+				return nil, err
+			}
+			break
+		}
+		dialer.client.begin(instance)
+
+		ipPort, ok := dialer.address(instance)
+		if !ok {
+			lastErr = fmt.Errorf("Instance %s of %s has no port to dial", instance.InstanceId, appId)
+			dialer.client.abandon(instance)
+			continue
+		}
+
+		start := time.Now()
+		conn, err := netDialer.DialContext(ctx, network, ipPort)
+		if nil != err && nil != ctx.Err() {
+			//调用方取消不代表实例故障
+			dialer.client.abandon(instance)
+			return nil, err
+		}
+		dialer.client.Report(instance, err, time.Since(start))
+		if nil == err {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("Failed to dial %s: %w", appId, lastErr)
+}
+
+func (dialer *Dialer) netDialer(policy *appPolicy) *net.Dialer {
+	if nil != dialer.Dialer {
+		return dialer.Dialer
+	}
+	return &net.Dialer{
+		Timeout:   time.Duration(policy.config.ConnectTimeoutMs) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+// 实例的拨号地址: 元数据中的端口、port、securePort
+func (dialer *Dialer) address(instance *core.Instance) (string, bool) {
+	key := dialer.PortMetadataKey
+	if isEmptyString(key) {
+		key = DefaultDialPortMetadataKey
+	}
+
+	if value := metadataString(instance, key); !isEmptyString(value) {
+		if port, err := strconv.Atoi(value); nil == err && 0 < port {
+			return net.JoinHostPort(instance.IpAddr, strconv.Itoa(port)), true
+		}
+	}
+	if portEnabled(instance.Port) {
+		return net.JoinHostPort(instance.IpAddr, strconv.Itoa(instance.Port.Port)), true
+	}
+	if portEnabled(instance.SecurePort) {
+		return net.JoinHostPort(instance.IpAddr, strconv.Itoa(instance.SecurePort.Port)), true
+	}
+	return "", false
+}
@@ -0,0 +1,127 @@
+package eureka
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+func newTestListener(t *testing.T) (net.Listener, int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			_, _ = conn.Write([]byte(listener.Addr().String()))
+			_ = conn.Close()
+		}
+	}()
+	return listener, listener.Addr().(*net.TCPAddr).Port
+}
+
+// 空闲端口, 连接会被拒绝
+func closedPort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	return port
+}
+
+// 优先使用元数据中的端口, 忽略地址中的端口
+func TestDialUsesMetadataPort(t *testing.T) {
+	_, port := newTestListener(t)
+	instance := upInstance("DEMO", "demo-1", "127.0.0.1", closedPort(t))
+	instance.Metadata = map[string]interface{}{DefaultDialPortMetadataKey: strconv.Itoa(port)}
+	client := newPolicyTestClient(t, config.ClientPolicy{}, instance)
+
+	conn, err := client.NewDialer(nil).Dial("tcp", "demo:6379")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().(*net.TCPAddr).Port; port != got {
+		t.Fatalf("dialed port %d, want %d", got, port)
+	}
+}
+
+// 连接失败时换一个实例, 失败上报给故障实例探测
+func TestDialFailsOverToNextInstance(t *testing.T) {
+	_, port := newTestListener(t)
+	client := newPolicyTestClient(t, config.ClientPolicy{},
+		upInstance("DEMO", "refused", "127.0.0.1", closedPort(t)),
+		upInstance("DEMO", "ok", "127.0.0.1", port))
+
+	for i := 0; i < 4; i++ {
+		conn, err := client.DialContext(context.Background(), "tcp", "DEMO")
+		if nil != err {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+	}
+	stats := client.outliers.get("refused")
+	if nil == stats {
+		t.Fatal("failed dial not reported")
+	}
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	if 0 == stats.failures {
+		t.Fatal("failed dial not reported")
+	}
+}
+
+// 所有实例都连接失败时返回最后一个错误, 取消的上下文不计为实例故障
+func TestDialErrors(t *testing.T) {
+	client := newPolicyTestClient(t, config.ClientPolicy{}, upInstance("DEMO", "refused", "127.0.0.1", closedPort(t)))
+	if _, err := client.NewDialer(nil).Dial("tcp", "DEMO"); nil == err {
+		t.Fatal("want error when every instance refuses")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	before := client.outliers.get("refused").requests
+	if _, err := client.DialContext(ctx, "tcp", "DEMO"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if after := client.outliers.get("refused").requests; before != after {
+		t.Fatal("canceled dial reported as a call")
+	}
+
+	if _, err := client.DialContext(context.Background(), "tcp", "MISSING"); nil == err {
+		t.Fatal("want error for unknown app")
+	}
+}
+
+func TestDialerAddress(t *testing.T) {
+	dialer := &Dialer{PortMetadataKey: "grpcPort"}
+	instance := &core.Instance{IpAddr: "10.0.0.1",
+		Metadata:   map[string]interface{}{"grpcPort": "9090", DefaultDialPortMetadataKey: "6379"},
+		Port:       &core.Port{Port: 80, Enabled: "false"},
+		SecurePort: &core.Port{Port: 443, Enabled: "true"}}
+	if address, ok := dialer.address(instance); !ok || "10.0.0.1:9090" != address {
+		t.Fatalf("got %s, %v", address, ok)
+	}
+
+	instance.Metadata = nil
+	if address, ok := dialer.address(instance); !ok || "10.0.0.1:443" != address {
+		t.Fatalf("got %s, %v, want securePort", address, ok)
+	}
+
+	instance.SecurePort.Enabled = "false"
+	if _, ok := dialer.address(instance); ok {
+		t.Fatal("want no address without enabled ports")
+	}
+}
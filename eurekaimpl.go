@@ -2,6 +2,7 @@ package eureka
 
 import (
 	"fmt"
+	"github.com/phpdragon/go-eureka-client/config"
 	"github.com/phpdragon/go-eureka-client/core"
 	netUtil "github.com/phpdragon/go-eureka-client/netutil"
 	"math/rand"
@@ -20,11 +21,15 @@ func (client *Client) Api() (*core.EurekaServerApi, error) {
 
 // rand to pick service url and new EurekaServerApi instance
 func (client *Client) pickEurekaServerApi() (*core.EurekaServerApi, error) {
-	if "" == client.config.ServiceURL.DefaultZone {
+	return pickEurekaServerApi(client.config.Load().ServiceURL.DefaultZone)
+}
+
+func pickEurekaServerApi(defaultZone string) (*core.EurekaServerApi, error) {
+	if "" == defaultZone {
 		return nil, fmt.Errorf("eureka.serviceUrl.defaultZone no setting!")
 	}
 
-	serviceUrls := strings.Split(client.config.ServiceURL.DefaultZone, ",")
+	serviceUrls := strings.Split(defaultZone, ",")
 	serviceUrl := ""
 	total := len(serviceUrls)
 	if total < 2 {
@@ -40,13 +45,13 @@ func (client *Client) pickEurekaServerApi() (*core.EurekaServerApi, error) {
 
 // 刷新服务列表
 func (client *Client) refreshRegistry() {
-	if !client.config.ClientConfig.FetchRegistry {
+	if !client.config.Load().ClientConfig.FetchRegistry {
 		return
 	}
 
 	for {
 		_ = client.fetchRegistry()
-		time.Sleep(time.Second * time.Duration(client.config.ClientConfig.GetRegistryFetchIntervalSeconds()))
+		time.Sleep(time.Second * time.Duration(client.config.Load().ClientConfig.GetRegistryFetchIntervalSeconds()))
 	}
 }
 
@@ -54,29 +59,25 @@ func (client *Client) refreshRegistry() {
 func (client *Client) fetchRegistry() error {
 	client.logger.Info("Fetch registry info")
 
-	apps, err := client.apiClient.QueryAllInstances()
+	apps, err := client.apiClient.Load().QueryAllInstances()
 	if err != nil {
 		client.logger.Error(fmt.Sprintf("Failed to QueryAllInstances, err=%s", err.Error()))
 		return err
 	}
 
-	registryApps := make(map[string]*core.Application)
-	activeInstances := make(map[string]map[int]*core.Instance)
-	activeServiceUrls := make(map[string]map[int]map[int]string)
+	reg := buildRegistry(client.config.Load().ClientConfig.FilterOnlyUpInstances, apps.Applications)
 
-	for _, app := range apps.Applications {
-		instances, urls := getActiveInstancesAndIpPorts(client.config.ClientConfig.FilterOnlyUpInstances, app.Instances)
-		registryApps[app.Name] = &app
-		activeInstances[app.Name] = instances
-		activeServiceUrls[app.Name] = urls
-	}
+	client.registryMutex.Lock()
+	defer client.registryMutex.Unlock()
 
-	client.mutex.Lock()
-	defer client.mutex.Unlock()
+	//首次抓取到的实例视为已预热
+	firstSeen := time.Now()
+	if nil == client.snapshot.Load() {
+		firstSeen = time.Time{}
+	}
 
-	client.registryAppMap = registryApps
-	client.activeInstanceMap = activeInstances
-	client.activeServiceIpPortMap = activeServiceUrls
+	client.snapshot.Store(reg)
+	client.outliers.retain(reg, firstSeen)
 
 	return nil
 }
@@ -84,31 +85,39 @@ func (client *Client) fetchRegistry() error {
 // register instance (default current status is STARTING)
 // and update instance status to UP
 func (client *Client) registerWithEureka() {
-	if !client.config.ClientConfig.RegisterWithEureka {
+	if !client.config.Load().ClientConfig.RegisterWithEureka {
 		client.logger.Warn("This instance don't register to eureka!")
 		return
 	}
 
+	if !client.checkInstanceId() {
+		client.mutex.Lock()
+		client.Running = false
+		client.mutex.Unlock()
+		return
+	}
+
 	for {
-		if client.instance == nil {
+		instance := client.instance.Load()
+		if instance == nil {
 			client.logger.Error("Config instance can't be nil")
 			return
 		}
 
-		err := client.apiClient.RegisterInstance(client.instance.App, client.instance)
+		err := client.apiClient.Load().RegisterInstance(instance.App, instance)
 		if err != nil {
 			client.logger.Error(fmt.Sprintf("client register failed, err=%s", err.Error()))
 			time.Sleep(time.Second * defaultSleepIntervals)
 			continue
 		}
-		client.logger.Info(fmt.Sprintf("Successfully register service to eureka with status[%s] !", client.instance.Status))
+		client.logger.Info(fmt.Sprintf("Successfully register service to eureka with status[%s] !", instance.Status))
 
 		break
 	}
 
 	go func() {
 		for {
-			enabledOnInit := client.config.InstanceConfig.InstanceEnabledOnInit
+			enabledOnInit := client.config.Load().InstanceConfig.InstanceEnabledOnInit
 			//如果向eureka注册后立即启用实例以获取流量，或者服务已经启动，则向eureka更新为在线状态
 			if enabledOnInit || (!enabledOnInit && client.serverIsStarted()) {
 				updated, err := client.updateInstanceStatus()
@@ -130,14 +139,62 @@ func (client *Client) registerWithEureka() {
 	go client.monitorClient()
 }
 
+// 注册前检查是否有其他主机的实例已使用相同的 instanceId, 返回是否继续注册
+// 查询失败(包括实例不存在)时视为没有冲突
+func (client *Client) checkInstanceId() bool {
+	action := client.config.Load().GetInstanceIdConflict()
+	instance := client.instance.Load()
+	if config.InstanceIdConflictIgnore == action || nil == instance {
+		return true
+	}
+
+	existing, err := client.apiClient.Load().QuerySpecificAppInstance(instance.InstanceId)
+	if nil != err || nil == existing || 0 == len(existing.IpAddr) || existing.HostName == instance.HostName {
+		return true
+	}
+	//已下线、停止服务或租约过期的注册不视为冲突
+	if !isLiveRegistration(existing, time.Now()) {
+		return true
+	}
+
+	message := fmt.Sprintf("InstanceId %s is already registered by app %s on host %s(%s), this host is %s(%s)",
+		instance.InstanceId, existing.App, existing.HostName, existing.IpAddr, instance.HostName, instance.IpAddr)
+	if config.InstanceIdConflictRefuse == action {
+		client.logger.Error(message + ", refuse to register!")
+		return false
+	}
+	client.logger.Warn(message + ", the registration will overwrite it!")
+	return true
+}
+
+// 注册中心中的实例是否状态为 UP 且租约未过期, 没有租约信息时只看状态
+func isLiveRegistration(instance *core.Instance, now time.Time) bool {
+	if core.STATUS_UP != instance.Status {
+		return false
+	}
+	lease := instance.LeaseInfo
+	if nil == lease || 0 >= lease.LastRenewalTimestamp {
+		return true
+	}
+	if 0 < lease.EvictionTimestamp {
+		return false
+	}
+	duration := lease.DurationInSecs
+	if 0 >= duration {
+		duration = config.DefaultLeaseDurationInSecs
+	}
+	return now.UnixMilli() < lease.LastRenewalTimestamp+int64(duration)*1000
+}
+
 // 判断http服务是否已经启动
 func (client *Client) serverIsStarted() bool {
-	port := client.instance.Port.Port
-	if "true" == client.instance.SecurePort.Enabled {
-		port = client.instance.SecurePort.Port
+	instance := client.instance.Load()
+	port := instance.Port.Port
+	if "true" == instance.SecurePort.Enabled {
+		port = instance.SecurePort.Port
 	}
 
-	used := netUtil.PortInUse(client.instance.IpAddr, port)
+	used := netUtil.PortInUse(instance.IpAddr, port)
 	client.logger.Debug(fmt.Sprintf("Check that the web server is started, result:%t", used))
 
 	return used
@@ -147,7 +204,8 @@ func (client *Client) serverIsStarted() bool {
 func (client *Client) updateInstanceStatus() (bool, error) {
 	client.logger.Info("Update the instance status to UP ...")
 
-	if client.instance == nil {
+	instance := client.instance.Load()
+	if instance == nil {
 		client.logger.Error("Config instance can't be nil")
 		return false, nil
 	}
@@ -155,14 +213,16 @@ func (client *Client) updateInstanceStatus() (bool, error) {
 	//如果成功注册到eureka并将状态更新到UP
 	// if success to register to eureka and update status to UP
 	// then break loop
-	err := client.apiClient.UpdateInstanceStatus(client.instance.App, client.instance.InstanceId, core.STATUS_UP)
+	err := client.apiClient.Load().UpdateInstanceStatus(instance.App, instance.InstanceId, core.STATUS_UP)
 	if err != nil {
 		client.logger.Error(fmt.Sprintf("client UP failed, err=%s", err.Error()))
 		return false, nil
 	}
 
 	//本地状态更新为up
-	client.instance.Status = core.STATUS_UP
+	client.updateInstance(func(instance *core.Instance) {
+		instance.Status = core.STATUS_UP
+	})
 
 	client.logger.Info("The server status[UP] was updated successfully !")
 
@@ -173,40 +233,43 @@ func (client *Client) updateInstanceStatus() (bool, error) {
 // eureka client heartbeat
 func (client *Client) heartbeat() {
 	for {
-		err := client.apiClient.SendHeartbeat(client.instance.App, client.instance.InstanceId)
+		instance := client.instance.Load()
+		err := client.apiClient.Load().SendHeartbeat(instance.App, instance.InstanceId)
 		if err != nil {
 			client.logger.Error(fmt.Sprintf("Failed to send heartbeat, err=%s", err.Error()))
 			time.Sleep(time.Second * defaultSleepIntervals)
 			continue
 		}
 
-		client.logger.Debug(fmt.Sprintf("Heartbeat app=%s, instanceId=%s", client.instance.App, client.instance.InstanceId))
-		time.Sleep(time.Duration(client.config.InstanceConfig.LeaseInfo.RenewalIntervalInSecs) * time.Second)
+		client.logger.Debug(fmt.Sprintf("Heartbeat app=%s, instanceId=%s", instance.App, instance.InstanceId))
+		time.Sleep(time.Duration(client.config.Load().InstanceConfig.LeaseInfo.RenewalIntervalInSecs) * time.Second)
 	}
 }
 
 // 监控客户端
 func (client *Client) monitorClient() {
-	eurekaUrl := client.config.ServiceURL.DefaultZone
-	eurekaUrl = strings.Replace(eurekaUrl, httpPrefix, "", -1)
-	eurekaUrl = strings.Replace(eurekaUrl, httpsPrefix, "", -1)
-	urls := strings.Split(eurekaUrl, "/")
-	eurekaIpPort := urls[0]
-
 	go func() {
 		for {
 			time.Sleep(time.Duration(60) * time.Second)
 
+			//注册中心地址可能被热更新
+			eurekaUrl := client.apiClient.Load().BaseUrl
+			eurekaUrl = strings.Replace(eurekaUrl, httpPrefix, "", -1)
+			eurekaUrl = strings.Replace(eurekaUrl, httpsPrefix, "", -1)
+			urls := strings.Split(eurekaUrl, "/")
+			eurekaIpPort := urls[0]
+
 			client.reRegistration(eurekaIpPort)
 
-			client.logger.Debug(fmt.Sprintf("monitor app=%s, instanceId=%s", client.instance.App, client.instance.InstanceId))
+			instance := client.instance.Load()
+			client.logger.Debug(fmt.Sprintf("monitor app=%s, instanceId=%s", instance.App, instance.InstanceId))
 		}
 	}()
 }
 
 // 重新注册
 func (client *Client) reRegistration(eurekaIpPort string) {
-	if core.STATUS_UP != client.instance.Status {
+	if core.STATUS_UP != client.instance.Load().Status {
 		return
 	}
 
@@ -216,14 +279,16 @@ func (client *Client) reRegistration(eurekaIpPort string) {
 	}
 
 	//存在记录注册记录
-	instance, err := client.apiClient.QuerySpecificAppInstance(client.instance.InstanceId)
-	if nil == err && nil != instance && 0 < len(instance.IpAddr) {
+	existing, err := client.apiClient.Load().QuerySpecificAppInstance(client.instance.Load().InstanceId)
+	if nil == err && nil != existing && 0 < len(existing.IpAddr) {
 		return
 	}
 
 	//不存在则重新注册
-	client.instance.Status = core.STATUS_UP
-	err = client.apiClient.RegisterInstance(client.instance.App, client.instance)
+	instance := client.updateInstance(func(instance *core.Instance) {
+		instance.Status = core.STATUS_UP
+	})
+	err = client.apiClient.Load().RegisterInstance(instance.App, instance)
 	if err != nil {
 		client.logger.Error(fmt.Sprintf("client re-register failed, err=%s", err.Error()))
 	} else {
@@ -0,0 +1,76 @@
+package eureka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+// 其他主机上使用了 instanceId "self" 的实例
+func conflictingInstance(status string, lastRenewal time.Time) core.Instance {
+	instance := upInstance("SELF", "self", "10.0.0.9", 8080)
+	instance.HostName = "other-host"
+	instance.Status = status
+	instance.LeaseInfo = &core.LeaseInfo{DurationInSecs: 90, LastRenewalTimestamp: lastRenewal.UnixMilli()}
+	return instance
+}
+
+func TestCheckInstanceId(t *testing.T) {
+	now := time.Now()
+	sameHost := conflictingInstance(core.STATUS_UP, now)
+	sameHost.HostName = "self"
+	evicted := conflictingInstance(core.STATUS_UP, now)
+	evicted.LeaseInfo.EvictionTimestamp = now.UnixMilli()
+	noLease := conflictingInstance(core.STATUS_UP, now)
+	noLease.LeaseInfo = nil
+
+	cases := []struct {
+		name     string
+		action   string
+		existing []core.Instance
+		want     bool
+	}{
+		{"not registered", config.InstanceIdConflictRefuse, nil, true},
+		{"same host", config.InstanceIdConflictRefuse, []core.Instance{sameHost}, true},
+		{"up and current", config.InstanceIdConflictRefuse, []core.Instance{conflictingInstance(core.STATUS_UP, now)}, false},
+		{"without lease info", config.InstanceIdConflictRefuse, []core.Instance{noLease}, false},
+		{"warn", config.InstanceIdConflictWarn, []core.Instance{conflictingInstance(core.STATUS_UP, now)}, true},
+		{"ignore", config.InstanceIdConflictIgnore, []core.Instance{conflictingInstance(core.STATUS_UP, now)}, true},
+		{"down", config.InstanceIdConflictRefuse, []core.Instance{conflictingInstance(core.STATUS_DOWN, now)}, true},
+		{"out of service", config.InstanceIdConflictRefuse, []core.Instance{conflictingInstance(core.STATUS_OUT_OF_SERVICE, now)}, true},
+		{"expired lease", config.InstanceIdConflictRefuse, []core.Instance{conflictingInstance(core.STATUS_UP, now.Add(-91*time.Second))}, true},
+		{"evicted", config.InstanceIdConflictRefuse, []core.Instance{evicted}, true},
+	}
+	for _, c := range cases {
+		var apps []core.Application
+		if 0 < len(c.existing) {
+			apps = append(apps, testApp("SELF", c.existing...))
+		}
+		stub := newEurekaStub(t, apps...)
+		client := newTestClient(t, stub, func(cfg *config.Config) {
+			cfg.InstanceConfig.InstanceIdConflict = c.action
+		})
+
+		if got := client.checkInstanceId(); c.want != got {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+		if queried := 0 < stub.count("GET /instances/self"); queried == (config.InstanceIdConflictIgnore == c.action) {
+			t.Errorf("%s: got queried %v", c.name, queried)
+		}
+	}
+}
+
+// 没有配置租约时长时按默认的90秒计算
+func TestIsLiveRegistrationDefaultDuration(t *testing.T) {
+	now := time.Now()
+	instance := conflictingInstance(core.STATUS_UP, now.Add(-60*time.Second))
+	instance.LeaseInfo.DurationInSecs = 0
+	if !isLiveRegistration(&instance, now) {
+		t.Fatal("want lease renewed 60s ago to be current")
+	}
+	if isLiveRegistration(&instance, now.Add(31*time.Second)) {
+		t.Fatal("want lease renewed 91s ago to be expired")
+	}
+}
@@ -0,0 +1,110 @@
+package eureka
+
+import (
+	"github.com/phpdragon/go-eureka-client/core"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// 尚无延迟数据但有进行中请求的实例的惩罚值, 同 Finagle PeakEwma
+const ewmaPenalty = float64(math.MaxInt64 >> 16)
+
+// 按延迟的负载均衡, 即 Finagle/Linkerd 的 P2C + PeakEWMA
+// 随机取两个候选实例, 选择 延迟EWMA * (进行中请求数+1) / 预热权重 较小的一个
+// 进行中请求数只统计 Transport、Dialer 发起的调用, 发起时加一, 上报结果或放弃后减一;
+// GetNextServerFromEureka、GetRealHttpUrl、Picker.Next 等只选择实例, 不计入进行中请求数
+type peakEwmaBalancer struct {
+	client *Client
+}
+
+func (balancer *peakEwmaBalancer) choose(candidates []*server) *server {
+	chosen := candidates[0]
+	if 1 < len(candidates) {
+		i := rand.Intn(len(candidates))
+		j := rand.Intn(len(candidates) - 1)
+		if j >= i {
+			j++
+		}
+
+		now := time.Now()
+		decay := balancer.client.ewmaDecay()
+		first := balancer.client.outliers.getOrCreate(candidates[i].instance.InstanceId)
+		second := balancer.client.outliers.getOrCreate(candidates[j].instance.InstanceId)
+		chosen = candidates[i]
+		//加1避免无负载时预热权重不起作用
+		firstLoad := (first.load(decay, now) + 1) / balancer.client.warmupWeight(candidates[i].instance, now)
+		secondLoad := (second.load(decay, now) + 1) / balancer.client.warmupWeight(candidates[j].instance, now)
+		if secondLoad < firstLoad {
+			chosen = candidates[j]
+		}
+	}
+	return chosen
+}
+
+// 开始一次对实例的调用, 进行中请求数加一, 须以 Client.Report 或 Client.abandon 结束
+func (client *Client) begin(instance *core.Instance) {
+	client.outliers.getOrCreate(instance.InstanceId).pending.Inc()
+}
+
+// 结束一个进行中的请求
+func (stats *instanceStats) done() {
+	//调用方自行选择实例后上报的调用不会计入进行中请求数, 不能减为负数
+	for {
+		pending := stats.pending.Load()
+		if 0 >= pending || stats.pending.CompareAndSwap(pending, pending-1) {
+			return
+		}
+	}
+}
+
+// 记录一次调用延迟并结束一个进行中的请求
+// 延迟高于当前值时直接取峰值, 否则按距上次更新的时间指数衰减;
+// 失败的调用至少按当前值的两倍计, 避免快速失败的实例被误认为低延迟
+func (stats *instanceStats) observe(decay time.Duration, now time.Time, latency time.Duration, failed bool) {
+	stats.done()
+
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	rtt := float64(latency)
+	if failed && rtt < 2*stats.latencyEwma {
+		rtt = 2 * stats.latencyEwma
+	}
+	stats.decayLatency(decay, now, rtt)
+}
+
+// 当前负载, 延迟EWMA * (进行中请求数+1)
+func (stats *instanceStats) load(decay time.Duration, now time.Time) float64 {
+	pending := float64(stats.pending.Load())
+
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	//没有新数据时延迟逐渐衰减, 让长时间未被选中的实例有机会重新被选中
+	stats.decayLatency(decay, now, 0)
+	if 0 == stats.latencyEwma && 0 < pending {
+		return ewmaPenalty + pending
+	}
+	return stats.latencyEwma * (pending + 1)
+}
+
+// 须持有 stats.mutex
+func (stats *instanceStats) decayLatency(decay time.Duration, now time.Time, rtt float64) {
+	elapsed := now.Sub(stats.latencyStamp)
+	if 0 > elapsed {
+		elapsed = 0
+	}
+	stats.latencyStamp = now
+
+	if rtt > stats.latencyEwma {
+		stats.latencyEwma = rtt
+		return
+	}
+	weight := math.Exp(-float64(elapsed) / float64(decay))
+	stats.latencyEwma = stats.latencyEwma*weight + rtt*(1-weight)
+}
+
+func (client *Client) ewmaDecay() time.Duration {
+	return time.Duration(client.config.Load().ClientConfig.GetEwmaDecaySeconds()) * time.Second
+}
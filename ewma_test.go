@@ -0,0 +1,134 @@
+package eureka
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+func usePeakEwma(cfg *config.Config) {
+	cfg.ClientConfig.LoadBalancer = config.LoadBalancerPeakEwma
+}
+
+func pendingOf(client *Client, instanceId string) int64 {
+	if stats := client.outliers.get(instanceId); nil != stats {
+		return stats.pending.Load()
+	}
+	return 0
+}
+
+// 优先选择延迟低的实例
+func TestPeakEwmaPrefersLowLatency(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO",
+		upInstance("DEMO", "demo-1", "10.0.0.1", 80),
+		upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+	client := newTestClient(t, stub, usePeakEwma)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	client.Report(registryInstance(client, "demo-1"), nil, 100*time.Millisecond)
+	client.Report(registryInstance(client, "demo-2"), nil, time.Millisecond)
+	for i := 0; i < 20; i++ {
+		instance, err := client.GetNextServerFromEureka("DEMO")
+		if nil != err || "demo-2" != instance.InstanceId {
+			t.Fatalf("got %s, %v, want demo-2", instanceIdOf(instance), err)
+		}
+	}
+}
+
+// 只选择实例而不上报结果的调用不计入进行中请求数
+func TestPeakEwmaLookupsDoNotLeakPending(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO",
+		upInstance("DEMO", "demo-1", "10.0.0.1", 80),
+		upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+	client := newTestClient(t, stub, usePeakEwma)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, err := client.GetNextServerFromEureka("DEMO"); nil != err {
+			t.Fatal(err)
+		}
+		if _, err := client.GetRealHttpUrl("http://demo/ping"); nil != err {
+			t.Fatal(err)
+		}
+		if _, err := client.NewPicker("DEMO").Next(); nil != err {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"demo-1", "demo-2"} {
+		if pending := pendingOf(client, id); 0 != pending {
+			t.Fatalf("%s has %d pending requests after lookups", id, pending)
+		}
+	}
+}
+
+// Transport 的每次调用(含重试)结束后进行中请求数归零
+func TestPeakEwmaTransportReleasesPending(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	stub := newEurekaStub(t, testApp("DEMO", serverInstance(t, "DEMO", "demo-1", failing)))
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		usePeakEwma(cfg)
+		cfg.Clients = map[string]config.ClientPolicy{"demo": {MaxAutoRetries: 2, RetryableStatusCodes: []int{503}}}
+	})
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	httpClient := &http.Client{Transport: client.NewTransport(nil)}
+	for i := 0; i < 5; i++ {
+		resp, err := httpClient.Get("http://DEMO/ping")
+		if nil != err {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if pending := pendingOf(client, "demo-1"); 0 != pending {
+		t.Fatalf("got %d pending requests, want 0", pending)
+	}
+}
+
+// Dialer 连接结束后进行中请求数归零
+func TestPeakEwmaDialerReleasesPending(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	noPort := upInstance("DEMO", "demo-2", "127.0.0.1", 0)
+	noPort.Port = &core.Port{Enabled: "false"}
+	stub := newEurekaStub(t, testApp("DEMO", upInstance("DEMO", "demo-1", "127.0.0.1", port), noPort))
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		usePeakEwma(cfg)
+		cfg.ClientConfig.FilterOnlyUpInstances = false
+	})
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		conn, err := client.NewDialer(nil).Dial("tcp", "DEMO:"+strconv.Itoa(port))
+		if nil != err {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+	}
+	for _, id := range []string{"demo-1", "demo-2"} {
+		if pending := pendingOf(client, id); 0 != pending {
+			t.Fatalf("%s has %d pending requests after dialing", id, pending)
+		}
+	}
+}
@@ -3,71 +3,151 @@ package eureka
 import (
 	"fmt"
 	"github.com/phpdragon/go-eureka-client/core"
+	"net/url"
 	"strings"
 )
 
 // 获取下一个容器
+// 返回的实例为注册表内部对象, 只读
 func (client *Client) GetNextServerFromEureka(appId string) (*core.Instance, error) {
-	instanceMap, err := client.getActiveInstancesByAppId(appId)
+	app, err := client.getAppServers(appId)
 	if nil != err {
 		return &core.Instance{}, err
 	}
 
-	if nil == instanceMap || 0 == len(instanceMap) {
+	if nil == app || 0 == len(app.servers) {
 		client.logger.Error(fmt.Sprintf("This %s instances not exist!", appId))
 		return &core.Instance{}, fmt.Errorf("This %s instances not exist!", appId)
 	}
 
-	index := client.getRandIndex(len(instanceMap))
-	return instanceMap[index], nil
+	target := client.selectServer(client.policy(appId), app.servers, nil)
+	if nil == target {
+		return &core.Instance{}, fmt.Errorf("This %s instances not exist!", appId)
+	}
+	return target.instance, nil
 }
 
 func (client *Client) GetRealHttpUrl(httpUrl string) (string, error) {
+	appName, schemeKey := parseHttpUrl(httpUrl)
+
+	app, err := client.getAppServers(appName)
+	if nil != err || nil == app {
+		return "", fmt.Errorf("This %s instances not exist!", appName)
+	}
+
+	return client.resolveHttpUrl(client.policy(appName), httpUrl, appName, app.endpoints[schemeKey])
+}
+
+// 根据vip获取下一个容器, secure 为true时按 SecureVipAddress 查找
+// 返回的实例为注册表内部对象, 只读
+func (client *Client) GetNextServerByVip(vipAddress string, secure bool) (*core.Instance, error) {
+	vip, err := client.getVipServers(vipAddress, secure)
+	if nil != err {
+		return &core.Instance{}, err
+	}
+
+	if nil == vip || 0 == len(vip.servers) {
+		client.logger.Error(fmt.Sprintf("This vip %s instances not exist!", vipAddress))
+		return &core.Instance{}, fmt.Errorf("This vip %s instances not exist!", vipAddress)
+	}
+
+	target := client.selectServer(client.defaultPolicy, vip.servers, nil)
+	if nil == target {
+		return &core.Instance{}, fmt.Errorf("This vip %s instances not exist!", vipAddress)
+	}
+	return target.instance, nil
+}
+
+// 获取vip下的有效实例, 可能来自多个应用, 返回的是副本
+func (client *Client) GetInstancesByVip(vipAddress string, secure bool) ([]*core.Instance, error) {
+	vip, err := client.getVipServers(vipAddress, secure)
+	if nil != err {
+		return nil, err
+	}
+
+	instances := make([]*core.Instance, len(vip.servers))
+	for i, s := range vip.servers {
+		instances[i] = s.instance.Clone()
+	}
+	return instances, nil
+}
+
+// 将 http://vipAddress/path 转换为真实地址, https 按 SecureVipAddress 查找
+func (client *Client) GetRealHttpUrlByVip(httpUrl string) (string, error) {
+	vipAddress, schemeKey := parseHttpUrl(httpUrl)
+
+	vip, err := client.getVipServers(vipAddress, httpsKey == schemeKey)
+	if nil != err || nil == vip {
+		return "", fmt.Errorf("This vip %s instances not exist!", vipAddress)
+	}
+
+	return client.resolveHttpUrl(client.defaultPolicy, httpUrl, vipAddress, vip.endpoints[schemeKey])
+}
+
+// 解析出url中的服务名(或vip)及协议
+func parseHttpUrl(httpUrl string) (string, int) {
 	httpUrlTmp := strings.Replace(httpUrl, httpPrefix, "", -1)
 	httpUrlTmp = strings.Replace(httpUrlTmp, httpsPrefix, "", -1)
 	urls := strings.Split(httpUrlTmp, "/")
-	appName := urls[0]
 
 	//是否https
-	mapKey := httpKey
+	schemeKey := httpKey
 	if strings.HasPrefix(httpUrl, httpsPrefix) {
-		mapKey = httpsKey
-	}
-
-	ipPortMap, err := client.getActiveServiceIpPortByAppId(appName)
-	if nil != err || 0 == len(ipPortMap) {
-		return "", fmt.Errorf("This %s instances not exist!", appName)
+		schemeKey = httpsKey
 	}
+	return urls[0], schemeKey
+}
 
+// 按负载均衡策略取一个可用的目标ip:port替换url中的服务名
+func (client *Client) resolveHttpUrl(policy *appPolicy, httpUrl string, name string, endpoints []*server) (string, error) {
 	//取http还是https的ip:port
-	realIpPorts := ipPortMap[mapKey]
-	if nil == realIpPorts || 0 == len(realIpPorts) {
-		return "", fmt.Errorf("This %s instances not exist!", appName)
+	target := client.selectServer(policy, endpoints, nil)
+	if nil == target {
+		return "", fmt.Errorf("This %s instances not exist!", name)
 	}
 
-	//随机取一个目标ip:port
-	index := client.getRandIndex(len(realIpPorts))
-	realIpPort := realIpPorts[index]
-
-	return strings.Replace(httpUrl, appName, realIpPort, -1), nil
+	//只替换主机部分, 路径及参数中出现的服务名保持不变
+	u, err := url.Parse(httpUrl)
+	if nil != err {
+		return "", err
+	}
+	u.Host = target.ipPort
+	return u.String(), nil
 }
 
+// 获取注册表中的应用列表, 返回的是副本, 修改不会影响注册表
 func (client *Client) GetApplications() map[string]*core.Application {
-	return client.registryAppMap
+	reg := client.registry()
+	apps := make(map[string]*core.Application, len(reg.apps))
+	for appId, app := range reg.apps {
+		apps[appId] = app.Clone()
+	}
+	return apps
 }
 
-func (client *Client) GetInstances() map[string]map[int]*core.Instance {
-	return client.activeInstanceMap
+// 获取各应用的有效实例, 返回的是副本, 修改不会影响注册表
+func (client *Client) GetInstances() map[string][]*core.Instance {
+	reg := client.registry()
+	instances := make(map[string][]*core.Instance, len(reg.servers))
+	for appId, app := range reg.servers {
+		copied := make([]*core.Instance, len(app.servers))
+		for i, s := range app.servers {
+			copied[i] = s.instance.Clone()
+		}
+		instances[appId] = copied
+	}
+	return instances
 }
 
 func (client *Client) GetAppName() string {
-	return client.config.InstanceConfig.AppName
+	return client.config.Load().InstanceConfig.AppName
 }
 
 func (client *Client) GetPort() int {
-	port := client.instance.Port.Port
-	if "true" == client.instance.SecurePort.Enabled {
-		port = client.instance.SecurePort.Port
+	instance := client.instance.Load()
+	port := instance.Port.Port
+	if "true" == instance.SecurePort.Enabled {
+		port = instance.SecurePort.Port
 	}
 	return port
 }
@@ -0,0 +1,104 @@
+package eureka
+
+import (
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/core"
+	"math"
+	"strconv"
+	"time"
+)
+
+// 实例元数据中的权重, 用于一致性哈希, 默认1
+const metadataWeightKey = "weight"
+
+// 一致性哈希(加权 rendezvous/HRW 哈希)
+// 对每个候选实例计算 -weight / ln(hash(key, instanceId)), 取最大者;
+// 实例上下线或被摘除时只有落在该实例上的key会重新映射
+func (client *Client) chooseForKey(candidates []*server, key string) *server {
+	now := time.Now()
+	var chosen *server
+	maxScore := math.Inf(-1)
+	for _, s := range candidates {
+		weight := instanceWeight(s.instance) * client.warmupWeight(s.instance, now)
+		//映射到 (0, 1) 区间
+		u := (float64(hashKey(key, s.instance.InstanceId)>>11) + 0.5) / (1 << 53)
+		score := -weight / math.Log(u)
+		if score > maxScore {
+			maxScore = score
+			chosen = s
+		}
+	}
+	return chosen
+}
+
+// 根据key获取下一个容器, 相同key在实例不变时总是落在同一个实例上
+// 返回的实例为注册表内部对象, 只读
+func (client *Client) GetNextServerForKey(appId string, key string) (*core.Instance, error) {
+	app, err := client.getAppServers(appId)
+	if nil != err {
+		return &core.Instance{}, err
+	}
+
+	if nil == app || 0 == len(app.servers) {
+		client.logger.Error(fmt.Sprintf("This %s instances not exist!", appId))
+		return &core.Instance{}, fmt.Errorf("This %s instances not exist!", appId)
+	}
+
+	candidates := client.candidates(client.policy(appId), app.servers, nil)
+	if 0 == len(candidates) {
+		return &core.Instance{}, fmt.Errorf("This %s instances not exist!", appId)
+	}
+	return client.chooseForKey(candidates, key).instance, nil
+}
+
+// 元数据中的权重, 未配置或非法时为1
+func instanceWeight(instance *core.Instance) float64 {
+	var weight float64
+	switch value := instance.Metadata[metadataWeightKey].(type) {
+	case string:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if nil != err {
+			return 1
+		}
+		weight = parsed
+	case float64:
+		weight = value
+	case int:
+		weight = float64(value)
+	default:
+		return 1
+	}
+
+	if 0 >= weight || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return 1
+	}
+	return weight
+}
+
+// FNV-1a 后再做 splitmix64 混淆, 不分配内存
+func hashKey(key string, instanceId string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	//分隔符, 避免key与instanceId拼接后产生歧义
+	h ^= 0xff
+	h *= prime64
+	for i := 0; i < len(instanceId); i++ {
+		h ^= uint64(instanceId[i])
+		h *= prime64
+	}
+
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
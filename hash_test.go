@@ -0,0 +1,98 @@
+package eureka
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+// 相同key总是落在同一个实例上
+func TestGetNextServerForKeyIsStable(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2", "demo-3")
+	for i := 0; i < 20; i++ {
+		key := "user-" + strconv.Itoa(i)
+		first, err := client.GetNextServerForKey("DEMO", key)
+		if nil != err {
+			t.Fatal(err)
+		}
+		for j := 0; j < 5; j++ {
+			if instance, _ := client.GetNextServerForKey("DEMO", key); first.InstanceId != instance.InstanceId {
+				t.Fatalf("key %s moved from %s to %s", key, first.InstanceId, instance.InstanceId)
+			}
+		}
+	}
+}
+
+// 实例被摘除时只有落在该实例上的key重新映射
+func TestGetNextServerForKeyRemapsOnlyEjected(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2", "demo-3", "demo-4")
+	before := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		key := "user-" + strconv.Itoa(i)
+		instance, err := client.GetNextServerForKey("DEMO", key)
+		if nil != err {
+			t.Fatal(err)
+		}
+		before[key] = instance.InstanceId
+	}
+
+	bad := registryInstance(client, "demo-1")
+	for i := 0; i < 5; i++ {
+		client.Report(bad, errCallFailed, 0)
+	}
+
+	for key, id := range before {
+		instance, _ := client.GetNextServerForKey("DEMO", key)
+		if "demo-1" == instance.InstanceId {
+			t.Fatalf("key %s still on ejected instance", key)
+		}
+		if "demo-1" != id && id != instance.InstanceId {
+			t.Fatalf("key %s moved from %s to %s although its instance is healthy", key, id, instance.InstanceId)
+		}
+	}
+}
+
+// 按元数据中的权重分配key
+func TestGetNextServerForKeyHonorsWeight(t *testing.T) {
+	heavy := upInstance("DEMO", "demo-1", "10.0.0.1", 80)
+	heavy.Metadata = map[string]interface{}{metadataWeightKey: "3"}
+	stub := newEurekaStub(t, testApp("DEMO", heavy, upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		instance, err := client.GetNextServerForKey("DEMO", strconv.Itoa(i))
+		if nil != err {
+			t.Fatal(err)
+		}
+		counts[instance.InstanceId]++
+	}
+	//期望 3:1
+	if counts["demo-1"] < 2700 || counts["demo-1"] > 3300 {
+		t.Fatalf("got %v, want about 3000 on demo-1", counts)
+	}
+}
+
+func TestInstanceWeight(t *testing.T) {
+	cases := map[interface{}]float64{"2.5": 2.5, 4.0: 4, 3: 3, "bad": 1, "-1": 1, nil: 1}
+	for value, want := range cases {
+		instance := &core.Instance{Metadata: map[string]interface{}{metadataWeightKey: value}}
+		if got := instanceWeight(instance); want != got {
+			t.Fatalf("weight %v: got %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestGetNextServerForKeyDoesNotAllocate(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2")
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = client.GetNextServerForKey("DEMO", "user-1")
+	})
+	if 0 != allocs {
+		t.Fatalf("got %v allocs per call, want 0", allocs)
+	}
+}
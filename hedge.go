@@ -0,0 +1,240 @@
+package eureka
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/atomic"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// 计算对冲延迟保留的最近成功调用的延迟样本数
+	hedgeWindowSize = 256
+	// 样本数达到此值才开始对冲
+	hedgeMinSamples = 20
+	// 每记录多少个样本重新计算一次对冲延迟
+	hedgeRecomputeEvery = 16
+	// 对冲预算最多累积的令牌数, 限制突发的对冲请求
+	hedgeBudgetMaxTokens = 10
+)
+
+// 对冲请求中先返回的一方胜出, 另一方以此原因取消
+var errHedgeLost = errors.New("hedged request lost")
+
+// HedgeStats 对冲请求统计
+type HedgeStats struct {
+	// 可对冲的请求数
+	Requests int64 `json:"requests"`
+	// 发出的对冲请求数
+	Hedged int64 `json:"hedged"`
+	// 对冲请求先返回的次数
+	Wins int64 `json:"wins"`
+	// 因超出对冲预算未发出对冲请求的次数
+	BudgetExhausted int64 `json:"budgetExhausted"`
+}
+
+// 全局对冲预算, 每个可对冲的请求积累 percent/100 个令牌, 每个对冲请求消耗一个令牌,
+// 保证对冲请求数不超过请求总数的 percent%
+type hedgeBudget struct {
+	mutex   sync.Mutex
+	percent int
+	// 以 1/100 个令牌为单位, 避免浮点累加的误差
+	tokens int
+
+	requests        atomic.Int64
+	hedged          atomic.Int64
+	wins            atomic.Int64
+	budgetExhausted atomic.Int64
+}
+
+func newHedgeBudget(percent int) *hedgeBudget {
+	return &hedgeBudget{percent: percent}
+}
+
+func (budget *hedgeBudget) deposit() {
+	budget.requests.Inc()
+
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	budget.tokens = min(budget.tokens+budget.percent, hedgeBudgetMaxTokens*100)
+}
+
+// 取一个令牌, 预算不足时返回false
+func (budget *hedgeBudget) withdraw() bool {
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	if 100 > budget.tokens {
+		budget.budgetExhausted.Inc()
+		return false
+	}
+	budget.tokens -= 100
+	return true
+}
+
+// 归还未使用的令牌
+func (budget *hedgeBudget) refund() {
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	budget.tokens = min(budget.tokens+100, hedgeBudgetMaxTokens*100)
+}
+
+// 最近成功调用的延迟, 用于计算对冲延迟
+type latencyWindow struct {
+	mutex      sync.Mutex
+	percentile float64
+	samples    [hedgeWindowSize]time.Duration
+	count      int
+	// 按百分位计算出的延迟, 样本不足时为0
+	delay time.Duration
+}
+
+func newLatencyWindow(percentile float64) *latencyWindow {
+	return &latencyWindow{percentile: percentile}
+}
+
+func (window *latencyWindow) record(latency time.Duration) {
+	window.mutex.Lock()
+	defer window.mutex.Unlock()
+
+	window.samples[window.count%hedgeWindowSize] = latency
+	window.count++
+	if hedgeMinSamples > window.count || (hedgeMinSamples != window.count && 0 != window.count%hedgeRecomputeEvery) {
+		return
+	}
+
+	n := window.count
+	if hedgeWindowSize < n {
+		n = hedgeWindowSize
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, window.samples[:n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	index := int(math.Ceil(window.percentile/100*float64(n))) - 1
+	if 0 > index {
+		index = 0
+	}
+	window.delay = sorted[index]
+}
+
+func (window *latencyWindow) get() time.Duration {
+	window.mutex.Lock()
+	defer window.mutex.Unlock()
+	return window.delay
+}
+
+// 对冲延迟, 为0时不对冲
+func (policy *appPolicy) hedgeDelay() time.Duration {
+	delay := policy.latencies.get()
+	if 0 >= delay {
+		return 0
+	}
+	if minDelay := time.Duration(policy.config.GetHedgeMinDelayMs()) * time.Millisecond; delay < minDelay {
+		return minDelay
+	}
+	return delay
+}
+
+type hedgeResult struct {
+	target *server
+	resp   *http.Response
+	err    error
+	cancel context.CancelCauseFunc
+}
+
+// 向选中的实例发送请求, 超过对冲延迟仍未返回时向另一个未尝试过的实例发送对冲请求,
+// 取先返回的成功结果并取消另一个请求; 返回结果对应的实例
+func (transport *Transport) sendHedged(req *http.Request, picker *Picker, target *server, policy *appPolicy, appId string) (*server, *http.Response, error) {
+	budget := transport.client.hedge
+	budget.deposit()
+
+	results := make(chan hedgeResult, 2)
+	cancels := make(map[*server]context.CancelCauseFunc, 2)
+	attempt := func(target *server, first bool) {
+		ctx, cancel := context.WithCancelCause(req.Context())
+		cancels[target] = cancel
+		go func() {
+			resp, err := transport.send(ctx, req, target, policy, first)
+			results <- hedgeResult{target: target, resp: resp, err: err, cancel: cancel}
+		}()
+	}
+
+	attempt(target, true)
+	pending := 1
+	if delay := policy.hedgeDelay(); 0 < delay {
+		timer := time.NewTimer(delay)
+		select {
+		case result := <-results:
+			timer.Stop()
+			return transport.finishHedge(result)
+		case <-timer.C:
+		}
+
+		if budget.withdraw() {
+			if hedgeTarget, err := transport.pick(picker, policy, appId); nil == err {
+				budget.hedged.Inc()
+				attempt(hedgeTarget, false)
+				pending++
+			} else {
+				budget.refund()
+			}
+		}
+	}
+
+	result := <-results
+	pending--
+	if 0 < pending && policy.shouldRetry(result.resp, result.err) {
+		//先返回的一方失败时等待另一方
+		transport.abandonHedge(policy, result)
+		result = <-results
+		pending--
+	}
+	if 0 < pending {
+		for other, cancel := range cancels {
+			if other != result.target {
+				cancel(errHedgeLost)
+			}
+		}
+		go transport.drainHedge(policy, results)
+	}
+	if result.target != target {
+		budget.wins.Inc()
+	}
+	return transport.finishHedge(result)
+}
+
+// 胜出的请求在响应体关闭后才释放上下文
+func (transport *Transport) finishHedge(result hedgeResult) (*server, *http.Response, error) {
+	resp, err := onResponseDone(result.resp, result.err, func() { result.cancel(nil) })
+	return result.target, resp, err
+}
+
+// 等待落败的请求返回后释放资源
+func (transport *Transport) drainHedge(policy *appPolicy, results chan hedgeResult) {
+	transport.abandonHedge(policy, <-results)
+}
+
+// 丢弃落败请求的结果并释放实例的并发名额
+func (transport *Transport) abandonHedge(policy *appPolicy, result hedgeResult) {
+	result.cancel(errHedgeLost)
+	if nil != result.resp {
+		discardResponse(result.resp)
+	}
+	if nil != policy.bulkhead {
+		policy.bulkhead.releaseInstance(result.target.instance.InstanceId)
+	}
+}
+
+// 获取对冲请求统计
+func (client *Client) HedgeStats() HedgeStats {
+	return HedgeStats{
+		Requests:        client.hedge.requests.Load(),
+		Hedged:          client.hedge.hedged.Load(),
+		Wins:            client.hedge.wins.Load(),
+		BudgetExhausted: client.hedge.budgetExhausted.Load(),
+	}
+}
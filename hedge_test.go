@@ -0,0 +1,109 @@
+package eureka
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+)
+
+// 首个实例超过对冲延迟未返回时向另一个实例发送对冲请求, 落败的请求不计为失败
+func TestHedgeUsesFasterInstance(t *testing.T) {
+	slow := newTestBackend(t, http.StatusOK, 2*time.Second)
+	fast := newTestBackend(t, http.StatusOK, 0)
+	client := newPolicyTestClient(t, config.ClientPolicy{HedgeEnabled: true, MaxConcurrentRequestsPerInstance: 5},
+		serverInstance(t, "DEMO", "slow", slow.Server), serverInstance(t, "DEMO", "fast", fast.Server))
+	policy := client.policy("DEMO")
+	for i := 0; i < hedgeMinSamples; i++ {
+		policy.latencies.record(20 * time.Millisecond)
+	}
+	client.hedge.tokens = hedgeBudgetMaxTokens * 100
+
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		if status, _ := doGet(t, client, "http://demo/ping"); http.StatusOK != status {
+			t.Fatalf("got status %d", status)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("took %s, hedged request not used", elapsed)
+		}
+	}
+
+	stats := client.HedgeStats()
+	if 4 != stats.Requests || 0 == stats.Hedged || stats.Hedged != stats.Wins {
+		t.Fatalf("got %+v, want every hedged request to win", stats)
+	}
+
+	//落败的请求被取消后释放实例名额和进行中请求数
+	deadline := time.Now().Add(time.Second)
+	for 0 != pendingOf(client, "slow") || 0 != client.BulkheadStats()["DEMO"].Inflight {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d pending, %+v", pendingOf(client, "slow"), client.BulkheadStats()["DEMO"])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	client.policy("DEMO").bulkhead.mutex.Lock()
+	inflight := len(client.policy("DEMO").bulkhead.instanceInflight)
+	client.policy("DEMO").bulkhead.mutex.Unlock()
+	if 0 != inflight {
+		t.Fatalf("got %d instances with inflight requests, want 0", inflight)
+	}
+
+	if stats := client.outliers.get("slow"); nil != stats {
+		stats.mutex.Lock()
+		failures := stats.consecutiveFailures
+		stats.mutex.Unlock()
+		if 0 != failures {
+			t.Fatalf("lost hedged requests reported as %d failures", failures)
+		}
+	}
+}
+
+// 样本不足时不对冲
+func TestHedgeNeedsSamples(t *testing.T) {
+	window := newLatencyWindow(50)
+	for i := 1; i < hedgeMinSamples; i++ {
+		window.record(time.Duration(i) * time.Millisecond)
+	}
+	if 0 != window.get() {
+		t.Fatalf("got delay %s before %d samples", window.get(), hedgeMinSamples)
+	}
+	window.record(hedgeMinSamples * time.Millisecond)
+	if 10*time.Millisecond != window.get() {
+		t.Fatalf("got delay %s, want median 10ms", window.get())
+	}
+}
+
+// 对冲延迟不低于 hedgeMinDelayMs
+func TestHedgeMinDelay(t *testing.T) {
+	policy := &appPolicy{config: config.ClientPolicy{HedgeMinDelayMs: 50}, latencies: newLatencyWindow(95)}
+	for i := 0; i < hedgeMinSamples; i++ {
+		policy.latencies.record(time.Millisecond)
+	}
+	if 50*time.Millisecond != policy.hedgeDelay() {
+		t.Fatalf("got %s, want 50ms", policy.hedgeDelay())
+	}
+}
+
+// 对冲请求数不超过请求总数的 percent%
+func TestHedgeBudget(t *testing.T) {
+	budget := newHedgeBudget(10)
+	for i := 0; i < 9; i++ {
+		budget.deposit()
+	}
+	if budget.withdraw() {
+		t.Fatal("withdrew before 10 requests")
+	}
+	budget.deposit()
+	if !budget.withdraw() || budget.withdraw() {
+		t.Fatal("want exactly one token after 10 requests")
+	}
+	budget.refund()
+	if !budget.withdraw() {
+		t.Fatal("refunded token not available")
+	}
+	if 2 != budget.budgetExhausted.Load() {
+		t.Fatalf("got %d exhausted, want 2", budget.budgetExhausted.Load())
+	}
+}
@@ -1,91 +1,138 @@
 package eureka
 
 import (
-	"fmt"
 	"github.com/phpdragon/go-eureka-client/core"
 	"strings"
-	"unsafe"
+	"time"
+	"unicode/utf8"
 )
 
-func (client *Client) getActiveServiceIpPortByAppId(appId string) (map[int]map[int]string, error) {
-	id := strings.ToUpper(appId)
-	cache := client.activeServiceIpPortMap[id]
-	if nil != cache {
-		return client.activeServiceIpPortMap[id], nil
-	}
-
-	err := client.doRefreshByAppId(appId)
-	if nil != err {
-		return nil, err
+// 当前注册表快照, 永不为nil
+func (client *Client) registry() *registry {
+	reg := client.snapshot.Load()
+	if nil == reg {
+		return emptyRegistry
 	}
-
-	return client.activeServiceIpPortMap[id], nil
+	return reg
 }
 
-func (client *Client) getActiveInstancesByAppId(appId string) (map[int]*core.Instance, error) {
-	id := strings.ToUpper(appId)
-	cache := client.activeInstanceMap[id]
+// 获取应用的有效实例, 本地缓存不存在时向eureka查询
+func (client *Client) getAppServers(appId string) (*appServers, error) {
+	//应用名通常已是大写, 先直接查找避免每次转换
+	reg := client.registry()
+	cache := reg.servers[appId]
+	if nil == cache {
+		var buf [64]byte
+		cache = reg.servers[string(upperAppId(buf[:0], appId))]
+	}
 	if nil != cache {
-		return client.activeInstanceMap[id], nil
+		client.loader.hits.Inc()
+		client.prober.touch(appId)
+		return cache, nil
 	}
 
-	err := client.doRefreshByAppId(appId)
-	if nil != err {
-		return nil, err
-	}
+	id := strings.ToUpper(appId)
+	client.loader.misses.Inc()
+	client.prober.touch(id)
+	return client.loader.load("app:"+id, id, client.notFoundCacheTtl(), func() (*appServers, error) {
+		//可能在等待期间已被其他查询加载
+		if cache := client.registry().servers[id]; nil != cache {
+			return cache, nil
+		}
 
-	return client.activeInstanceMap[id], nil
+		err := client.doRefreshByAppId(id)
+		if nil != err {
+			return nil, err
+		}
+		return client.registry().servers[id], nil
+	})
 }
 
 func (client *Client) doRefreshByAppId(appId string) error {
-	application, errr := client.apiClient.QueryAllInstanceByAppId(appId)
+	application, errr := client.apiClient.Load().QueryAllInstanceByAppId(appId)
 	if errr != nil {
 		return errr
 	}
+	if 0 == len(application.Name) {
+		application.Name = appId
+	}
 
-	instances, urls := getActiveInstancesAndIpPorts(client.config.ClientConfig.FilterOnlyUpInstances, application.Instances)
-
-	client.mutex.Lock()
-	defer client.mutex.Unlock()
+	client.registryMutex.Lock()
+	defer client.registryMutex.Unlock()
 
-	client.registryAppMap[appId] = application
-	client.activeInstanceMap[appId] = instances
-	client.activeServiceIpPortMap[appId] = urls
+	reg := client.registry().withApp(client.config.Load().ClientConfig.FilterOnlyUpInstances, application)
+	client.snapshot.Store(reg)
 
 	return nil
 }
 
-// 获取有效的实例和链接
-func getActiveInstancesAndIpPorts(filterOnlyUpInstances bool, instances []core.Instance) (map[int]*core.Instance, map[int]map[int]string) {
-	instancesX := make(map[int]*core.Instance)
-	//
-	urls := make(map[int]map[int]string)
-	httpUrls := make(map[int]string)
-	httpsUrls := make(map[int]string)
-	instanceTotal := len(instances)
-	for i := 0; i < instanceTotal; i++ {
-		instance := instances[i]
-
-		if filterOnlyUpInstances && core.STATUS_UP != instance.Status {
-			continue
+// 获取vip下的有效实例, 本地缓存不存在时向eureka查询
+// secure 为true时按 SecureVipAddress 查找
+// 将应用名的大写形式追加到buf, 以 m[string(upper)] 查找时不分配
+// 含非ascii字符时按 strings.ToUpper 转换
+func upperAppId(buf []byte, appId string) []byte {
+	for i := 0; i < len(appId); i++ {
+		c := appId[i]
+		if c >= utf8.RuneSelf {
+			return append(buf[:0], strings.ToUpper(appId)...)
+		}
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
 		}
+		buf = append(buf, c)
+	}
+	return buf
+}
 
-		instancesX[i] = &instance
+func (client *Client) getVipServers(vipAddress string, secure bool) (*appServers, error) {
+	vip := strings.ToLower(vipAddress)
+	reg := client.registry()
+	cache := reg.vips[vip]
+	key := "vip:" + vip
+	if secure {
+		cache = reg.svips[vip]
+		key = "svip:" + vip
+	}
+	if nil != cache {
+		client.loader.hits.Inc()
+		return cache, nil
+	}
 
-		if "true" == instance.Port.Enabled {
-			httpUrls[i] = fmt.Sprintf("%s:%d", instance.IpAddr, instance.Port.Port)
-		}
-		if "false" == instance.SecurePort.Enabled {
-			httpsUrls[i] = fmt.Sprintf("%s:%d", instance.IpAddr, instance.SecurePort.Port)
-		}
+	client.loader.misses.Inc()
+	return client.loader.load(key, "vip "+vip, client.notFoundCacheTtl(), func() (*appServers, error) {
+		return client.doRefreshByVip(vip, secure)
+	})
+}
+
+func (client *Client) doRefreshByVip(vipAddress string, secure bool) (*appServers, error) {
+	var apps *core.Applications
+	var err error
+	if secure {
+		apps, err = client.apiClient.Load().QueryAllInstancesBySvipAddress(vipAddress)
+	} else {
+		apps, err = client.apiClient.Load().QueryAllInstancesByVipAddress(vipAddress)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]*core.Instance, 0)
+	for i := range apps.Applications {
+		instances = append(instances, instancePointers(apps.Applications[i].Instances)...)
 	}
 
-	urls[httpKey] = httpUrls
-	urls[httpsKey] = httpsUrls
-	return instancesX, urls
+	client.registryMutex.Lock()
+	defer client.registryMutex.Unlock()
+
+	reg := client.registry().withVip(client.config.Load().ClientConfig.FilterOnlyUpInstances, secure, vipAddress, instances)
+	client.snapshot.Store(reg)
+
+	if secure {
+		return reg.svips[vipAddress], nil
+	}
+	return reg.vips[vipAddress], nil
 }
 
-func (client *Client) getRandIndex(total int) int {
-	var index64 = client.autoIncr.Inc() % int64(total)
-	return *(*int)(unsafe.Pointer(&index64))
+func (client *Client) notFoundCacheTtl() time.Duration {
+	return time.Duration(client.config.Load().ClientConfig.GetNotFoundCacheTtlSeconds()) * time.Second
 }
@@ -0,0 +1,110 @@
+package eureka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/core"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// 非2xx响应保留的响应体长度
+const maxErrorBodySize = 4096
+
+// StatusError 下游应用返回了非2xx响应
+type StatusError struct {
+	AppId string
+	// 返回响应的实例
+	Instance   *core.Instance
+	StatusCode int
+	// 响应体, 最多保留 4KB
+	Body []byte
+}
+
+func (err *StatusError) Error() string {
+	instanceId := ""
+	if nil != err.Instance {
+		instanceId = err.Instance.InstanceId
+	}
+	return fmt.Sprintf("Request to %s(%s) failed, status=%d, body=%s", err.AppId, instanceId, err.StatusCode, err.Body)
+}
+
+// Invoke 调用下游应用的json接口, 如 Invoke[Req, Resp](ctx, client, "DEMO-SERVICE", http.MethodPost, "/action", req)
+// 通过 Transport 发送, 按应用的调用策略选择协议、实例、超时和重试; req 为nil时不发送请求体, 204或空响应体时返回零值
+// 返回最终处理请求的实例用于日志, 未选中任何实例时为nil;
+// 非2xx响应返回 *StatusError, 被舱壁或限流拒绝时返回 *RejectedError
+func Invoke[Req any, Resp any](ctx context.Context, client *Client, appId string, method string, path string, req Req) (Resp, *core.Instance, error) {
+	var resp Resp
+
+	var body io.Reader
+	if !isNil(req) {
+		data, err := json.Marshal(req)
+		if nil != err {
+			return resp, nil, fmt.Errorf("Failed to encode request to %s: %w", appId, err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	chosen := &chosenInstance{}
+	ctx = context.WithValue(ctx, chosenInstanceKey{}, chosen)
+	scheme := client.policy(appId).config.GetScheme()
+	request, err := http.NewRequestWithContext(ctx, method, scheme+"://"+appId+"/"+strings.TrimLeft(path, "/"), body)
+	if nil != err {
+		return resp, nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if nil != body {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := (&http.Client{Transport: client.NewTransport(nil)}).Do(request)
+	if nil != err {
+		return resp, chosen.instance, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if http.StatusOK > response.StatusCode || http.StatusMultipleChoices <= response.StatusCode {
+		errBody, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+		return resp, chosen.instance, &StatusError{AppId: appId, Instance: chosen.instance, StatusCode: response.StatusCode, Body: errBody}
+	}
+	if http.StatusNoContent == response.StatusCode {
+		return resp, chosen.instance, nil
+	}
+
+	if err = json.NewDecoder(response.Body).Decode(&resp); nil != err && !errors.Is(err, io.EOF) {
+		return resp, chosen.instance, fmt.Errorf("Failed to decode response of %s: %w", appId, err)
+	}
+	return resp, chosen.instance, nil
+}
+
+// Transport 将最终处理请求的实例写入请求上下文中的 chosenInstance
+type chosenInstanceKey struct{}
+
+type chosenInstance struct {
+	instance *core.Instance
+}
+
+func recordChosenInstance(ctx context.Context, instance *core.Instance) {
+	if chosen, ok := ctx.Value(chosenInstanceKey{}).(*chosenInstance); ok {
+		chosen.instance = instance
+	}
+}
+
+func isNil(value interface{}) bool {
+	if nil == value {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
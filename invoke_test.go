@@ -0,0 +1,145 @@
+package eureka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+type echoRequest struct {
+	Name string `json:"name"`
+}
+
+type echoResponse struct {
+	Greeting string `json:"greeting"`
+	Method   string `json:"method"`
+}
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hello":
+			var req echoRequest
+			if nil != r.Body {
+				_ = json.NewDecoder(r.Body).Decode(&req)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(echoResponse{Greeting: "hello " + req.Name, Method: r.Method})
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/bad":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, "invalid name")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// 编码请求、解码响应, 返回处理请求的实例
+func TestInvokeJson(t *testing.T) {
+	server := newEchoServer(t)
+	client := newPolicyTestClient(t, config.ClientPolicy{}, serverInstance(t, "DEMO", "demo-1", server))
+
+	resp, instance, err := Invoke[*echoRequest, echoResponse](context.Background(), client, "DEMO", http.MethodPost, "hello", &echoRequest{Name: "eureka"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "hello eureka" != resp.Greeting || http.MethodPost != resp.Method {
+		t.Fatalf("got %+v", resp)
+	}
+	if "demo-1" != instanceIdOf(instance) {
+		t.Fatalf("got instance %s, want demo-1", instanceIdOf(instance))
+	}
+
+	//请求为nil时不发送请求体
+	resp, _, err = Invoke[*echoRequest, echoResponse](context.Background(), client, "DEMO", http.MethodGet, "/hello", nil)
+	if nil != err || "hello " != resp.Greeting {
+		t.Fatalf("got %+v, %v", resp, err)
+	}
+}
+
+// 204 返回零值
+func TestInvokeNoContent(t *testing.T) {
+	server := newEchoServer(t)
+	client := newPolicyTestClient(t, config.ClientPolicy{}, serverInstance(t, "DEMO", "demo-1", server))
+
+	resp, _, err := Invoke[any, *echoResponse](context.Background(), client, "DEMO", http.MethodDelete, "/empty", nil)
+	if nil != err || nil != resp {
+		t.Fatalf("got %+v, %v, want zero value", resp, err)
+	}
+}
+
+// 非2xx响应返回 *StatusError
+func TestInvokeStatusError(t *testing.T) {
+	server := newEchoServer(t)
+	client := newPolicyTestClient(t, config.ClientPolicy{}, serverInstance(t, "DEMO", "demo-1", server))
+
+	_, _, err := Invoke[any, echoResponse](context.Background(), client, "DEMO", http.MethodGet, "/bad", nil)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("got %v, want *StatusError", err)
+	}
+	if http.StatusBadRequest != statusErr.StatusCode || "invalid name" != string(statusErr.Body) || "demo-1" != instanceIdOf(statusErr.Instance) {
+		t.Fatalf("got %+v", statusErr)
+	}
+}
+
+// scheme 为 https 时通过https端口调用, 只选择启用了https端口的实例
+func TestInvokeHttps(t *testing.T) {
+	server := newEchoServer(t)
+	tlsServer := httptest.NewTLSServer(server.Config.Handler)
+	t.Cleanup(tlsServer.Close)
+	secure := upInstance("DEMO", "demo-2", "127.0.0.1", 80)
+	secure.Port.Enabled = "false"
+	secure.SecurePort = &core.Port{Port: tlsServer.Listener.Addr().(*net.TCPAddr).Port, Enabled: "true"}
+	client := newPolicyTestClient(t, config.ClientPolicy{Scheme: config.SchemeHttps}, serverInstance(t, "DEMO", "demo-1", server), secure)
+	client.policy("DEMO").transport = tlsServer.Client().Transport
+
+	for i := 0; i < 4; i++ {
+		resp, instance, err := Invoke[*echoRequest, echoResponse](context.Background(), client, "DEMO", http.MethodPost, "/hello", &echoRequest{Name: "tls"})
+		if nil != err || "hello tls" != resp.Greeting || "demo-2" != instanceIdOf(instance) {
+			t.Fatalf("got %+v, %s, %v, want demo-2", resp, instanceIdOf(instance), err)
+		}
+	}
+}
+
+// 被限流拒绝时返回 *RejectedError, 不选中任何实例
+func TestInvokeRejected(t *testing.T) {
+	server := newEchoServer(t)
+	client := newPolicyTestClient(t, config.ClientPolicy{RateLimitPerSecond: 1}, serverInstance(t, "DEMO", "demo-1", server))
+
+	if _, _, err := Invoke[any, echoResponse](context.Background(), client, "DEMO", http.MethodGet, "/hello", nil); nil != err {
+		t.Fatal(err)
+	}
+	_, instance, err := Invoke[any, echoResponse](context.Background(), client, "DEMO", http.MethodGet, "/hello", nil)
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || nil != instance {
+		t.Fatalf("got %v, %v, want rejection without instance", err, instance)
+	}
+}
+
+func TestIsNil(t *testing.T) {
+	var request *echoRequest
+	var instance interface{} = (*core.Instance)(nil)
+	for _, value := range []interface{}{nil, request, instance, map[string]string(nil), []int(nil)} {
+		if !isNil(value) {
+			t.Fatalf("%#v should be nil", value)
+		}
+	}
+	for _, value := range []interface{}{0, "", echoRequest{}, &echoRequest{}} {
+		if isNil(value) {
+			t.Fatalf("%#v should not be nil", value)
+		}
+	}
+}
@@ -18,56 +18,56 @@ func NewLogAgent(zapLogger *zap.SugaredLogger) *Logger {
 
 func (log *Logger) Debug(args ...interface{}) {
 	if nil != log.zapLogger {
-		log.zapLogger.Debug(args)
+		log.zapLogger.Debug(args...)
 	} else {
-		log.baseLog.Println(args)
+		log.baseLog.Println(args...)
 	}
 }
 
 func (log *Logger) Info(args ...interface{}) {
 	if nil != log.zapLogger {
-		log.zapLogger.Info(args)
+		log.zapLogger.Info(args...)
 	} else {
-		log.baseLog.Println(args)
+		log.baseLog.Println(args...)
 	}
 }
 
 func (log *Logger) Warn(args ...interface{}) {
 	if nil != log.zapLogger {
-		log.zapLogger.Warn(args)
+		log.zapLogger.Warn(args...)
 	} else {
-		log.baseLog.Println(args)
+		log.baseLog.Println(args...)
 	}
 }
 
 func (log *Logger) Error(args ...interface{}) {
 	if nil != log.zapLogger {
-		log.zapLogger.Error(args)
+		log.zapLogger.Error(args...)
 	} else {
-		log.baseLog.Println(args)
+		log.baseLog.Println(args...)
 	}
 }
 
 func (log *Logger) Panic(args ...interface{}) {
 	if nil != log.zapLogger {
-		log.zapLogger.Panic(args)
+		log.zapLogger.Panic(args...)
 	} else {
-		log.baseLog.Panic(args)
+		log.baseLog.Panic(args...)
 	}
 }
 
 func (log *Logger) DPanic(args ...interface{}) {
 	if nil != log.zapLogger {
-		log.zapLogger.DPanic(args)
+		log.zapLogger.DPanic(args...)
 	} else {
-		log.baseLog.Println(args)
+		log.baseLog.Println(args...)
 	}
 }
 
 func (log *Logger) Fatal(args ...interface{}) {
 	if nil != log.zapLogger {
-		log.zapLogger.Fatal(args)
+		log.zapLogger.Fatal(args...)
 	} else {
-		log.baseLog.Fatal(args)
+		log.baseLog.Fatal(args...)
 	}
 }
@@ -0,0 +1,121 @@
+package eureka
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/core"
+	"go.uber.org/atomic"
+	"sync"
+	"time"
+)
+
+// CacheStats 本地注册表缓存的命中统计
+type CacheStats struct {
+	// 直接命中本地注册表的次数
+	Hits int64 `json:"hits"`
+	// 本地注册表未命中的次数
+	Misses int64 `json:"misses"`
+	// 命中"不存在"缓存的次数
+	NotFoundHits int64 `json:"notFoundHits"`
+	// 实际向eureka发起查询的次数
+	Loads int64 `json:"loads"`
+	// 合并到其他并发查询上的次数
+	SharedLoads int64 `json:"sharedLoads"`
+}
+
+// 按需查询加载器
+// 同一个key的并发查询合并为一次请求, 不存在的结果缓存一段时间, 避免击穿eureka服务端
+type appLoader struct {
+	mutex sync.Mutex
+	// 进行中的查询
+	calls map[string]*loadCall
+	// key: 查询key, value: 过期时间
+	notFound map[string]time.Time
+
+	hits         atomic.Int64
+	misses       atomic.Int64
+	notFoundHits atomic.Int64
+	loads        atomic.Int64
+	sharedLoads  atomic.Int64
+}
+
+type loadCall struct {
+	done    chan struct{}
+	servers *appServers
+	err     error
+}
+
+func newAppLoader() *appLoader {
+	return &appLoader{
+		calls:    make(map[string]*loadCall),
+		notFound: make(map[string]time.Time),
+	}
+}
+
+// 执行查询, 同一个key同时只有一个查询在进行, name 为错误信息中的应用名或vip
+func (loader *appLoader) load(key string, name string, ttl time.Duration, fn func() (*appServers, error)) (*appServers, error) {
+	loader.mutex.Lock()
+	if expireAt, ok := loader.notFound[key]; ok {
+		if time.Now().Before(expireAt) {
+			loader.mutex.Unlock()
+			loader.notFoundHits.Inc()
+			return nil, fmt.Errorf("This %s instances not exist! err=%w", name, core.ErrNotFound)
+		}
+		delete(loader.notFound, key)
+	}
+
+	if call, ok := loader.calls[key]; ok {
+		loader.mutex.Unlock()
+		loader.sharedLoads.Inc()
+		<-call.done
+		return call.servers, call.err
+	}
+
+	call := &loadCall{done: make(chan struct{})}
+	loader.calls[key] = call
+	loader.mutex.Unlock()
+
+	//fn panic 时也要唤醒等待者, 等待者得到下面的错误
+	defer loader.finish(key, ttl, call)
+	call.err = fmt.Errorf("Failed to load %s instances, query panicked", name)
+
+	loader.loads.Inc()
+	call.servers, call.err = fn()
+	if errors.Is(call.err, core.ErrNotFound) {
+		call.err = fmt.Errorf("This %s instances not exist! err=%w", name, call.err)
+	}
+	return call.servers, call.err
+}
+
+// 结束查询: 移除进行中的查询, 缓存不存在的结果并唤醒等待者
+func (loader *appLoader) finish(key string, ttl time.Duration, call *loadCall) {
+	loader.mutex.Lock()
+	delete(loader.calls, key)
+	if 0 < ttl && errors.Is(call.err, core.ErrNotFound) {
+		now := time.Now()
+		//插入时清理已过期的记录, 避免不再查询的key一直占用内存
+		for k, expireAt := range loader.notFound {
+			if !now.Before(expireAt) {
+				delete(loader.notFound, k)
+			}
+		}
+		loader.notFound[key] = now.Add(ttl)
+	}
+	loader.mutex.Unlock()
+	close(call.done)
+}
+
+func (loader *appLoader) stats() CacheStats {
+	return CacheStats{
+		Hits:         loader.hits.Load(),
+		Misses:       loader.misses.Load(),
+		NotFoundHits: loader.notFoundHits.Load(),
+		Loads:        loader.loads.Load(),
+		SharedLoads:  loader.sharedLoads.Load(),
+	}
+}
+
+// 获取本地注册表缓存的命中统计
+func (client *Client) CacheStats() CacheStats {
+	return client.loader.stats()
+}
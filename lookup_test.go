@@ -0,0 +1,137 @@
+package eureka
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+// 同一个key的并发查询只执行一次
+func TestLoaderSharesConcurrentLoads(t *testing.T) {
+	loader := newAppLoader()
+	release := make(chan struct{})
+	var calls sync.WaitGroup
+	calls.Add(1)
+	result := &appServers{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			servers, err := loader.load("app:DEMO", "DEMO", time.Minute, func() (*appServers, error) {
+				calls.Done()
+				<-release
+				return result, nil
+			})
+			if nil != err || result != servers {
+				t.Errorf("got %p, %v", servers, err)
+			}
+		}()
+	}
+	calls.Wait()
+	for 7 > loader.sharedLoads.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if 1 != loader.loads.Load() {
+		t.Fatalf("got %d loads, want 1", loader.loads.Load())
+	}
+}
+
+// 不存在的结果缓存 ttl 时间, 错误信息为应用名
+func TestLoaderCachesNotFound(t *testing.T) {
+	stub := newEurekaStub(t)
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.ClientConfig.NotFoundCacheTtlSeconds = 60
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetNextServerFromEureka("nope")
+		if !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("got %v, want ErrNotFound", err)
+		}
+		if strings.Contains(err.Error(), "app:") {
+			t.Fatalf("error leaks the internal key: %s", err.Error())
+		}
+	}
+	if n := stub.count("GET /apps/NOPE"); 1 != n {
+		t.Fatalf("got %d queries, want 1", n)
+	}
+	if 2 != client.CacheStats().NotFoundHits {
+		t.Fatalf("got %d not found hits, want 2", client.CacheStats().NotFoundHits)
+	}
+
+	_, err := client.GetInstancesByVip("nope", false)
+	if nil == err || !strings.Contains(err.Error(), "vip nope") {
+		t.Fatalf("got %v, want vip name in error", err)
+	}
+}
+
+// 缓存新的不存在结果时清理已过期的记录
+func TestLoaderSweepsExpiredNotFound(t *testing.T) {
+	loader := newAppLoader()
+	notFound := func() (*appServers, error) { return nil, core.ErrNotFound }
+
+	_, _ = loader.load("app:A", "A", time.Millisecond, notFound)
+	time.Sleep(5 * time.Millisecond)
+	_, _ = loader.load("app:B", "B", time.Minute, notFound)
+
+	loader.mutex.Lock()
+	defer loader.mutex.Unlock()
+	if _, ok := loader.notFound["app:A"]; ok || 1 != len(loader.notFound) {
+		t.Fatalf("expired entry not swept: %v", loader.notFound)
+	}
+}
+
+// 查询 panic 时等待者得到错误而不是一直阻塞, 之后可以重新查询
+func TestLoaderPanicReleasesWaiters(t *testing.T) {
+	loader := newAppLoader()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { _ = recover() }()
+		_, _ = loader.load("app:DEMO", "DEMO", time.Minute, func() (*appServers, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := loader.load("app:DEMO", "DEMO", time.Minute, func() (*appServers, error) {
+			return nil, errors.New("should share the panicked load")
+		})
+		errs <- err
+	}()
+	for 1 > loader.sharedLoads.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	select {
+	case err := <-errs:
+		if nil == err || !strings.Contains(err.Error(), "panicked") {
+			t.Fatalf("got %v, want panic error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter blocked after panic")
+	}
+
+	servers, err := loader.load("app:DEMO", "DEMO", time.Minute, func() (*appServers, error) {
+		return &appServers{}, nil
+	})
+	if nil != err || nil == servers {
+		t.Fatalf("got %v, %v after panic", servers, err)
+	}
+}
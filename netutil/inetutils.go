@@ -0,0 +1,132 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// InetOptions 选择本机ip的规则, 同 spring cloud 的 spring.cloud.inetutils.*
+type InetOptions struct {
+	// 忽略的网卡名, 正则表达式, 如 docker0、veth.*
+	IgnoredInterfaces []string
+	// 优先的网段, CIDR(如 10.0.0.0/8)或ip前缀(如 192.168), 配置后只选择匹配的地址
+	PreferredNetworks []string
+	// 只选择内网地址(10/8、172.16/12、192.168/16、fc00::/7)
+	UseOnlySiteLocalInterfaces bool
+	// 优先选择ipv6地址, 默认优先ipv4, 没有可用的优先地址时选择另一种
+	PreferIpv6 bool
+}
+
+// 网卡及其地址
+type inetInterface struct {
+	name  string
+	index int
+	addrs []net.IP
+}
+
+// FindLocalIp 按规则选择本机ip: 跳过未启用、回环及忽略的网卡, 按网卡序号从小到大选择第一个符合规则的地址,
+// 不会选择回环和链路本地地址
+func FindLocalIp(options InetOptions) (string, error) {
+	netInterfaces, err := net.Interfaces()
+	if nil != err {
+		return "", err
+	}
+
+	interfaces := make([]inetInterface, 0, len(netInterfaces))
+	for _, netInterface := range netInterfaces {
+		if 0 == netInterface.Flags&net.FlagUp || 0 != netInterface.Flags&net.FlagLoopback {
+			continue
+		}
+		addrs, err := netInterface.Addrs()
+		if nil != err {
+			continue
+		}
+		candidate := inetInterface{name: netInterface.Name, index: netInterface.Index}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				candidate.addrs = append(candidate.addrs, ipNet.IP)
+			}
+		}
+		interfaces = append(interfaces, candidate)
+	}
+
+	ip, err := selectIp(interfaces, options)
+	if nil != err {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+func selectIp(interfaces []inetInterface, options InetOptions) (net.IP, error) {
+	ignored := make([]*regexp.Regexp, 0, len(options.IgnoredInterfaces))
+	for _, pattern := range options.IgnoredInterfaces {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if nil != err {
+			return nil, fmt.Errorf("Invalid ignored interface pattern %s: %w", pattern, err)
+		}
+		ignored = append(ignored, re)
+	}
+
+	sort.SliceStable(interfaces, func(i, j int) bool {
+		return interfaces[i].index < interfaces[j].index
+	})
+
+	var preferred, fallback net.IP
+	for _, candidate := range interfaces {
+		if isIgnored(candidate.name, ignored) {
+			continue
+		}
+		for _, ip := range candidate.addrs {
+			if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || !isPreferredIp(ip, options) {
+				continue
+			}
+			if options.PreferIpv6 == (nil == ip.To4()) {
+				if nil == preferred {
+					preferred = ip
+				}
+			} else if nil == fallback {
+				fallback = ip
+			}
+		}
+		if nil != preferred {
+			return preferred, nil
+		}
+	}
+	if nil != fallback {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("No local ip address matches the interface rules")
+}
+
+func isIgnored(name string, ignored []*regexp.Regexp) bool {
+	for _, re := range ignored {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPreferredIp(ip net.IP, options InetOptions) bool {
+	if options.UseOnlySiteLocalInterfaces && !ip.IsPrivate() {
+		return false
+	}
+	if 0 == len(options.PreferredNetworks) {
+		return true
+	}
+	for _, network := range options.PreferredNetworks {
+		if _, cidr, err := net.ParseCIDR(network); nil == err {
+			if cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(ip.String(), network) {
+			return true
+		}
+	}
+	return false
+}
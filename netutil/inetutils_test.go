@@ -0,0 +1,60 @@
+package netutil
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func testInterfaces() []inetInterface {
+	return []inetInterface{
+		{name: "vpn0", index: 5, addrs: []net.IP{net.ParseIP("203.0.113.9")}},
+		{name: "docker0", index: 1, addrs: []net.IP{net.ParseIP("172.17.0.1")}},
+		{name: "eth0", index: 2, addrs: []net.IP{net.ParseIP("fe80::1"), net.ParseIP("2001:db8::2"), net.ParseIP("10.0.0.2")}},
+		{name: "eth1", index: 3, addrs: []net.IP{net.ParseIP("192.168.1.3")}},
+	}
+}
+
+func TestSelectIp(t *testing.T) {
+	cases := []struct {
+		name    string
+		options InetOptions
+		want    string
+	}{
+		{"lowest index", InetOptions{}, "172.17.0.1"},
+		{"ignored interfaces", InetOptions{IgnoredInterfaces: []string{"docker.*", "veth.*"}}, "10.0.0.2"},
+		{"preferred cidr", InetOptions{PreferredNetworks: []string{"192.168.0.0/16"}}, "192.168.1.3"},
+		{"preferred prefix", InetOptions{PreferredNetworks: []string{"10.0"}}, "10.0.0.2"},
+		{"site local only", InetOptions{IgnoredInterfaces: []string{"docker0", "eth.*"}, UseOnlySiteLocalInterfaces: true}, ""},
+		{"public when not site local only", InetOptions{IgnoredInterfaces: []string{"docker0", "eth.*"}}, "203.0.113.9"},
+		{"prefer ipv6", InetOptions{PreferIpv6: true}, "2001:db8::2"},
+		{"ipv6 fallback to ipv4", InetOptions{PreferIpv6: true, IgnoredInterfaces: []string{"eth0"}}, "172.17.0.1"},
+	}
+	for _, c := range cases {
+		ip, err := selectIp(testInterfaces(), c.options)
+		if 0 == len(c.want) {
+			if nil == err {
+				t.Errorf("%s: got %s, want error", c.name, ip)
+			}
+			continue
+		}
+		if nil != err || c.want != ip.String() {
+			t.Errorf("%s: got %v, %v, want %s", c.name, ip, err, c.want)
+		}
+	}
+}
+
+// 忽略的网卡名必须完整匹配
+func TestSelectIpIgnoredInterfaceMatchesWholeName(t *testing.T) {
+	ip, err := selectIp(testInterfaces(), InetOptions{IgnoredInterfaces: []string{"docker"}})
+	if nil != err || "172.17.0.1" != ip.String() {
+		t.Fatalf("got %v, %v, want docker0 not ignored", ip, err)
+	}
+}
+
+func TestSelectIpInvalidPattern(t *testing.T) {
+	_, err := selectIp(testInterfaces(), InetOptions{IgnoredInterfaces: []string{"veth("}})
+	if nil == err || !strings.Contains(err.Error(), "veth(") {
+		t.Fatalf("got %v, want invalid pattern error", err)
+	}
+}
@@ -0,0 +1,231 @@
+package eureka
+
+import (
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+	"go.uber.org/atomic"
+	"sync"
+	"time"
+)
+
+// 单个实例的调用统计
+type instanceStats struct {
+	mutex sync.Mutex
+
+	// 首次在注册表刷新中发现的时间, 创建后不再修改
+	// 客户端启动时已存在的实例为零值, 视为已预热
+	firstSeen time.Time
+
+	// 连续失败次数
+	consecutiveFailures int
+	// 当前统计周期
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	// 已连续摘除的次数, 用于计算指数递增的摘除时长
+	ejections int
+	// 摘除截止时间(unix nano), 选择实例时无锁读取
+	ejectedUntil atomic.Int64
+
+	// 延迟的峰值指数加权移动平均(ns), 见 peakEwmaBalancer
+	latencyEwma float64
+	// 上次更新 latencyEwma 的时间
+	latencyStamp time.Time
+	// 进行中的请求数
+	pending atomic.Int64
+}
+
+// 故障实例探测, 按上报的调用结果临时摘除异常实例
+type outlierDetector struct {
+	mutex sync.RWMutex
+	// key: instanceId
+	stats map[string]*instanceStats
+
+	// 串行化摘除操作, 保证不超过摘除比例上限
+	ejectMutex sync.Mutex
+	// 所有实例中最晚的摘除截止时间(unix nano), 已过期时无需逐个检查实例是否被摘除
+	ejectedUntil atomic.Int64
+}
+
+func newOutlierDetector() *outlierDetector {
+	return &outlierDetector{
+		stats: make(map[string]*instanceStats),
+	}
+}
+
+func (detector *outlierDetector) get(instanceId string) *instanceStats {
+	detector.mutex.RLock()
+	defer detector.mutex.RUnlock()
+	return detector.stats[instanceId]
+}
+
+func (detector *outlierDetector) getOrCreate(instanceId string) *instanceStats {
+	if stats := detector.get(instanceId); nil != stats {
+		return stats
+	}
+
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	stats := detector.stats[instanceId]
+	if nil == stats {
+		stats = &instanceStats{windowStart: time.Now()}
+		detector.stats[instanceId] = stats
+	}
+	return stats
+}
+
+// 与注册表同步: 清理已消失实例的统计, 为新出现的实例记录首次发现时间
+func (detector *outlierDetector) retain(reg *registry, firstSeen time.Time) {
+	instanceIds := reg.instanceIds()
+
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+
+	for instanceId := range detector.stats {
+		if _, ok := instanceIds[instanceId]; !ok {
+			delete(detector.stats, instanceId)
+		}
+	}
+	for instanceId := range instanceIds {
+		if _, ok := detector.stats[instanceId]; !ok {
+			detector.stats[instanceId] = &instanceStats{firstSeen: firstSeen, windowStart: time.Now()}
+		}
+	}
+}
+
+// 实例当前是否被摘除
+func (detector *outlierDetector) isEjected(instance *core.Instance, now int64) bool {
+	stats := detector.get(instance.InstanceId)
+	return nil != stats && now < stats.ejectedUntil.Load()
+}
+
+// 记录一次调用结果, 返回是否达到摘除条件
+func (stats *instanceStats) record(cfg *config.OutlierDetectionConfig, now time.Time, failed bool) bool {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	interval := time.Duration(cfg.GetIntervalSeconds()) * time.Second
+	if now.Sub(stats.windowStart) >= interval {
+		stats.windowStart = now
+		stats.requests = 0
+		stats.failures = 0
+	}
+
+	stats.requests++
+	if !failed {
+		stats.consecutiveFailures = 0
+		return false
+	}
+	stats.failures++
+	stats.consecutiveFailures++
+
+	if now.UnixNano() < stats.ejectedUntil.Load() {
+		return false
+	}
+	if limit := cfg.GetConsecutiveFailures(); 0 < limit && stats.consecutiveFailures >= limit {
+		return true
+	}
+	if rate := cfg.GetFailureRatePercent(); 0 < rate && stats.requests >= cfg.GetMinRequests() &&
+		stats.failures*100 >= rate*stats.requests {
+		return true
+	}
+	return false
+}
+
+// 摘除实例, 返回摘除时长
+// 上次摘除结束后超过最长摘除时长未再被摘除, 则重新从首次摘除时长开始计算
+func (stats *instanceStats) eject(cfg *config.OutlierDetectionConfig, now time.Time) time.Duration {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	maxEjection := time.Duration(cfg.GetMaxEjectionSeconds()) * time.Second
+	if now.UnixNano()-stats.ejectedUntil.Load() > int64(maxEjection) {
+		stats.ejections = 0
+	}
+	stats.ejections++
+
+	duration := time.Duration(cfg.GetBaseEjectionSeconds()) * time.Second
+	for i := 1; i < stats.ejections && duration < maxEjection; i++ {
+		duration *= 2
+	}
+	if duration > maxEjection {
+		duration = maxEjection
+	}
+
+	stats.ejectedUntil.Store(now.Add(duration).UnixNano())
+	stats.consecutiveFailures = 0
+	stats.windowStart = now
+	stats.requests = 0
+	stats.failures = 0
+	return duration
+}
+
+// Report 上报一次对实例的调用结果, err 为nil表示调用成功
+// 连续失败或错误率过高的实例将被临时摘除, 摘除期间不会被选中
+// latency 为本次调用耗时, 用于按延迟的负载均衡
+func (client *Client) Report(instance *core.Instance, err error, latency time.Duration) {
+	if nil == instance || 0 == len(instance.InstanceId) {
+		return
+	}
+
+	cfg := &client.config.Load().ClientConfig.OutlierDetection
+	now := time.Now()
+	stats := client.outliers.getOrCreate(instance.InstanceId)
+	stats.observe(client.ewmaDecay(), now, latency, nil != err)
+	if !stats.record(cfg, now, nil != err) {
+		return
+	}
+
+	client.tryEject(instance, stats, cfg, now, err)
+}
+
+// 放弃一次调用(如落败的对冲请求), 只结束进行中的请求, 不计入延迟和失败
+func (client *Client) abandon(instance *core.Instance) {
+	if stats := client.outliers.get(instance.InstanceId); nil != stats {
+		stats.done()
+	}
+}
+
+// 在不超过应用摘除比例上限的前提下摘除实例
+func (client *Client) tryEject(instance *core.Instance, stats *instanceStats, cfg *config.OutlierDetectionConfig, now time.Time, cause error) {
+	percent := cfg.GetMaxEjectionPercent()
+	if 0 >= percent {
+		return
+	}
+
+	client.outliers.ejectMutex.Lock()
+	defer client.outliers.ejectMutex.Unlock()
+
+	//按选择实例时可见的全部实例计算, 包括只通过vip查询得到的应用, 至少包括当前实例
+	instances := client.registry().appInstances(instance.App)
+	instances[instance.InstanceId] = instance
+	ejected := 0
+	for _, other := range instances {
+		if client.outliers.isEjected(other, now.UnixNano()) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > percent*len(instances) {
+		client.logger.Warn(fmt.Sprintf("Instance %s reached the ejection limit of app %s, ejected=%d, total=%d",
+			instance.InstanceId, instance.App, ejected, len(instances)))
+		return
+	}
+
+	duration := stats.eject(cfg, now)
+	if until := stats.ejectedUntil.Load(); until > client.outliers.ejectedUntil.Load() {
+		client.outliers.ejectedUntil.Store(until)
+	}
+	client.logger.Warn(fmt.Sprintf("Eject instance %s of app %s for %s, err=%v", instance.InstanceId, instance.App, duration, cause))
+}
+
+// 是否可能存在被摘除或被主动健康检查标记为不健康的实例, 无锁
+func (client *Client) mayBeUnavailable(now int64) bool {
+	return now < client.outliers.ejectedUntil.Load() || 0 < client.prober.unhealthy.Load()
+}
+
+// 实例未被摘除且未被主动健康检查标记为不健康
+func (client *Client) isAvailable(instance *core.Instance, now int64) bool {
+	return !client.outliers.isEjected(instance, now) && !client.prober.isUnhealthy(instance)
+}
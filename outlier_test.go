@@ -0,0 +1,182 @@
+package eureka
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+var errCallFailed = errors.New("call failed")
+
+func newOutlierTestClient(t *testing.T, ids ...string) *Client {
+	instances := make([]core.Instance, len(ids))
+	for i, id := range ids {
+		instances[i] = upInstance("DEMO", id, "10.0.0."+strconv.Itoa(i+1), 80)
+	}
+	stub := newEurekaStub(t, testApp("DEMO", instances...))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func registryInstance(client *Client, id string) *core.Instance {
+	for _, s := range client.registry().servers["DEMO"].servers {
+		if id == s.instance.InstanceId {
+			return s.instance
+		}
+	}
+	return nil
+}
+
+// 连续失败达到阈值后摘除, 摘除期间不会被选中
+func TestConsecutiveFailuresEject(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2")
+	bad := registryInstance(client, "demo-1")
+
+	for i := 0; i < 4; i++ {
+		client.Report(bad, errCallFailed, 0)
+	}
+	if client.outliers.isEjected(bad, time.Now().UnixNano()) {
+		t.Fatal("ejected before reaching consecutiveFailures")
+	}
+	client.Report(bad, errCallFailed, 0)
+	if !client.outliers.isEjected(bad, time.Now().UnixNano()) {
+		t.Fatal("not ejected after 5 consecutive failures")
+	}
+
+	for i := 0; i < 10; i++ {
+		instance, err := client.GetNextServerFromEureka("DEMO")
+		if nil != err || "demo-2" != instance.InstanceId {
+			t.Fatalf("got %s, %v, want demo-2", instanceIdOf(instance), err)
+		}
+	}
+}
+
+// 成功调用重置连续失败次数
+func TestSuccessResetsConsecutiveFailures(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2")
+	bad := registryInstance(client, "demo-1")
+
+	for i := 0; i < 8; i++ {
+		if 4 == i {
+			client.Report(bad, nil, 0)
+		}
+		client.Report(bad, errCallFailed, 0)
+	}
+	if client.outliers.isEjected(bad, time.Now().UnixNano()) {
+		t.Fatal("ejected although failures were not consecutive")
+	}
+}
+
+// 错误率在请求数达到 minRequests 后才计算
+func TestFailureRateEject(t *testing.T) {
+	cfg := &config.OutlierDetectionConfig{ConsecutiveFailures: -1, FailureRatePercent: 50, MinRequests: 4}
+	stats := &instanceStats{windowStart: time.Now()}
+	now := time.Now()
+
+	results := []bool{false, true, false}
+	for _, failed := range results {
+		if stats.record(cfg, now, failed) {
+			t.Fatal("ejected before minRequests")
+		}
+	}
+	if !stats.record(cfg, now, true) {
+		t.Fatal("not ejected at 50% failure rate")
+	}
+}
+
+// 再次摘除时摘除时长按指数递增, 不超过最长摘除时长
+func TestEjectionBackoff(t *testing.T) {
+	cfg := &config.OutlierDetectionConfig{BaseEjectionSeconds: 10, MaxEjectionSeconds: 30}
+	stats := &instanceStats{windowStart: time.Now()}
+	now := time.Now()
+
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, duration := range want {
+		if got := stats.eject(cfg, now); duration != got {
+			t.Fatalf("ejection %d: got %s, want %s", i+1, got, duration)
+		}
+		now = now.Add(duration)
+	}
+
+	//上次摘除结束后超过最长摘除时长, 重新从首次摘除时长开始
+	now = now.Add(31 * time.Second)
+	if got := stats.eject(cfg, now); 10*time.Second != got {
+		t.Fatalf("got %s after a long healthy period, want 10s", got)
+	}
+}
+
+// 同一应用摘除的实例不超过 maxEjectionPercent
+func TestMaxEjectionPercent(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2")
+	for _, id := range []string{"demo-1", "demo-2"} {
+		instance := registryInstance(client, id)
+		for i := 0; i < 5; i++ {
+			client.Report(instance, errCallFailed, 0)
+		}
+	}
+
+	now := time.Now().UnixNano()
+	if !client.outliers.isEjected(registryInstance(client, "demo-1"), now) {
+		t.Fatal("demo-1 not ejected")
+	}
+	if client.outliers.isEjected(registryInstance(client, "demo-2"), now) {
+		t.Fatal("demo-2 ejected beyond the 50% limit")
+	}
+}
+
+// 刷新注册表时清理已消失实例的统计
+func TestRetainDropsVanishedInstances(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO", upInstance("DEMO", "demo-1", "10.0.0.1", 80)))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	if nil == client.outliers.get("demo-1") {
+		t.Fatal("no stats for demo-1")
+	}
+
+	stub.setApps(testApp("DEMO", upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	if nil != client.outliers.get("demo-1") || nil == client.outliers.get("demo-2") {
+		t.Fatal("stats not synchronized with the registry")
+	}
+}
+
+// 只通过vip查询得到的应用同样不超过 maxEjectionPercent
+func TestMaxEjectionPercentByVip(t *testing.T) {
+	instances := make([]core.Instance, 2)
+	for i := range instances {
+		instances[i] = upInstance("DEMO", "demo-"+strconv.Itoa(i+1), "10.0.0."+strconv.Itoa(i+1), 80)
+		instances[i].VipAddress = "shared"
+	}
+	stub := newEurekaStub(t, testApp("DEMO", instances...))
+	client := newTestClient(t, stub, nil)
+
+	for i := 0; i < 20; i++ {
+		instance, err := client.GetNextServerByVip("shared", false)
+		if nil != err {
+			t.Fatal(err)
+		}
+		client.Report(instance, errCallFailed, 0)
+	}
+
+	ejected := 0
+	now := time.Now().UnixNano()
+	for _, s := range client.registry().vips["shared"].servers {
+		if client.outliers.isEjected(s.instance, now) {
+			ejected++
+		}
+	}
+	if 1 != ejected {
+		t.Fatalf("got %d ejected instances, want 1 of 2", ejected)
+	}
+}
@@ -0,0 +1,86 @@
+package eureka
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+// ErrNoMoreInstances 应用的实例均已尝试过
+var ErrNoMoreInstances = errors.New("no more instances")
+
+// Picker 单次请求内的实例选择器
+// 按配置的负载均衡策略依次返回不重复的实例, 用于失败重试时换一个实例, 非并发安全
+type Picker struct {
+	client    *Client
+	appId     string
+	schemeKey int
+	// 已返回过的实例
+	tried []*core.Instance
+}
+
+// NewPicker 创建应用的实例选择器, 每个请求创建一个
+func (client *Client) NewPicker(appId string) *Picker {
+	return client.newPicker(appId, anyKey)
+}
+
+// schemeKey 为 httpKey/httpsKey 时只选择启用了对应端口的实例
+func (client *Client) newPicker(appId string, schemeKey int) *Picker {
+	return &Picker{client: client, appId: appId, schemeKey: schemeKey}
+}
+
+// Next 返回下一个未尝试过的实例, 全部尝试过后返回 ErrNoMoreInstances
+func (picker *Picker) Next() (*core.Instance, error) {
+	s, err := picker.next()
+	if nil != err {
+		return nil, err
+	}
+	return s.instance, nil
+}
+
+// Tried 已返回过的实例
+func (picker *Picker) Tried() []*core.Instance {
+	return picker.tried
+}
+
+func (picker *Picker) next() (*server, error) {
+	s, err := picker.client.nextServer(picker.appId, picker.schemeKey, picker.tried)
+	if nil != err {
+		return nil, err
+	}
+
+	picker.tried = append(picker.tried, s.instance)
+	return s, nil
+}
+
+// 选择应用下一个未尝试过的实例, 全部尝试过后返回 ErrNoMoreInstances
+func (client *Client) nextServer(appId string, schemeKey int, tried []*core.Instance) (*server, error) {
+	app, err := client.getAppServers(appId)
+	if nil != err {
+		return nil, err
+	}
+
+	var servers []*server
+	if nil != app {
+		servers = app.list(schemeKey)
+	}
+	if 0 == len(servers) {
+		return nil, fmt.Errorf("This %s instances not exist!", appId)
+	}
+
+	s := client.selectServer(client.policy(appId), servers, tried)
+	if nil == s {
+		return nil, fmt.Errorf("All %d instances of %s have been tried: %w", len(tried), appId, ErrNoMoreInstances)
+	}
+	return s, nil
+}
+
+// 获取下一个容器, 排除已尝试过的实例, 全部尝试过后返回 ErrNoMoreInstances
+// 返回的实例为注册表内部对象, 只读, 不修改调用方的切片
+func (client *Client) GetNextServerExcluding(appId string, tried ...*core.Instance) (*core.Instance, error) {
+	s, err := client.nextServer(appId, anyKey, tried)
+	if nil != err {
+		return nil, err
+	}
+	return s.instance, nil
+}
@@ -0,0 +1,78 @@
+package eureka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+// Picker 依次返回不重复的实例, 全部尝试过后返回 ErrNoMoreInstances
+func TestPickerReturnsEachInstanceOnce(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2", "demo-3")
+	picker := client.NewPicker("demo")
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		instance, err := picker.Next()
+		if nil != err {
+			t.Fatal(err)
+		}
+		if seen[instance.InstanceId] {
+			t.Fatalf("%s returned twice", instance.InstanceId)
+		}
+		seen[instance.InstanceId] = true
+	}
+	if _, err := picker.Next(); !errors.Is(err, ErrNoMoreInstances) {
+		t.Fatalf("got %v, want ErrNoMoreInstances", err)
+	}
+	if 3 != len(picker.Tried()) {
+		t.Fatalf("got %d tried, want 3", len(picker.Tried()))
+	}
+}
+
+// 排除已尝试过的实例, 不修改调用方的切片
+func TestGetNextServerExcluding(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2", "demo-3")
+	first := registryInstance(client, "demo-1")
+	second := registryInstance(client, "demo-2")
+
+	tried := make([]*core.Instance, 2, 4)
+	tried[0], tried[1] = first, second
+	for i := 0; i < 5; i++ {
+		instance, err := client.GetNextServerExcluding("DEMO", tried...)
+		if nil != err || "demo-3" != instance.InstanceId {
+			t.Fatalf("got %s, %v, want demo-3", instanceIdOf(instance), err)
+		}
+	}
+	if extra := tried[:3][2]; nil != extra {
+		t.Fatalf("caller's slice was written: %s", extra.InstanceId)
+	}
+
+	tried = append(tried, registryInstance(client, "demo-3"))
+	if _, err := client.GetNextServerExcluding("DEMO", tried...); !errors.Is(err, ErrNoMoreInstances) {
+		t.Fatalf("got %v, want ErrNoMoreInstances", err)
+	}
+}
+
+// 未尝试过的实例均被摘除时, 仍返回未尝试过的实例
+func TestPickerFallsBackToEjectedInstances(t *testing.T) {
+	client := newOutlierTestClient(t, "demo-1", "demo-2", "demo-3")
+	bad := registryInstance(client, "demo-3")
+	for i := 0; i < 5; i++ {
+		client.Report(bad, errCallFailed, 0)
+	}
+
+	picker := client.NewPicker("DEMO")
+	var last string
+	for i := 0; i < 3; i++ {
+		instance, err := picker.Next()
+		if nil != err {
+			t.Fatal(err)
+		}
+		last = instance.InstanceId
+	}
+	if "demo-3" != last {
+		t.Fatalf("got %s last, want ejected demo-3", last)
+	}
+}
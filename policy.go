@@ -0,0 +1,152 @@
+package eureka
+
+import (
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 实例元数据中的可用区, 同 spring cloud 的 eureka.instance.metadata-map.zone
+const metadataZoneKey = "zone"
+
+// 下游应用的调用策略
+type appPolicy struct {
+	config config.ClientPolicy
+
+	// 负载均衡策略
+	balancer loadBalancer
+
+	// 配置了连接超时时使用的 RoundTripper, Transport 未指定 Base 时使用
+	transport http.RoundTripper
+
+	// 需要重试的响应码
+	retryableStatusCodes map[int]struct{}
+
+	// 舱壁和限流, 未配置时为nil
+	bulkhead *bulkhead
+
+	// 最近成功调用的延迟, 未开启对冲时为nil
+	latencies *latencyWindow
+}
+
+// 根据配置创建各下游应用的调用策略
+// key: appId
+func (client *Client) newPolicies() (map[string]*appPolicy, error) {
+	policies := make(map[string]*appPolicy, len(client.config.Load().Clients))
+	for appId, policyConfig := range client.config.Load().Clients {
+		policy, err := client.newPolicy(policyConfig)
+		if nil != err {
+			return nil, fmt.Errorf("eureka.clients.%s: %s", appId, err.Error())
+		}
+		policies[strings.ToUpper(appId)] = policy
+	}
+	return policies, nil
+}
+
+func (client *Client) newPolicy(policyConfig config.ClientPolicy) (*appPolicy, error) {
+	policy := &appPolicy{
+		config:               policyConfig,
+		balancer:             client.balancer,
+		retryableStatusCodes: make(map[int]struct{}, len(policyConfig.RetryableStatusCodes)),
+		bulkhead:             newBulkhead(&policyConfig),
+	}
+
+	if policyConfig.HedgeEnabled {
+		policy.latencies = newLatencyWindow(policyConfig.GetHedgeDelayPercentile())
+	}
+
+	if !isEmptyString(policyConfig.LoadBalancer) {
+		balancer, err := newLoadBalancer(policyConfig.LoadBalancer, client)
+		if nil != err {
+			return nil, err
+		}
+		policy.balancer = balancer
+	}
+
+	if 0 < policyConfig.ConnectTimeoutMs {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = (&net.Dialer{
+			Timeout:   time.Duration(policyConfig.ConnectTimeoutMs) * time.Millisecond,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		policy.transport = transport
+	}
+
+	for _, code := range policyConfig.RetryableStatusCodes {
+		policy.retryableStatusCodes[code] = struct{}{}
+	}
+	return policy, nil
+}
+
+// 获取应用的调用策略, 未配置时返回默认策略
+func (client *Client) policy(appId string) *appPolicy {
+	if policy := client.policies[appId]; nil != policy {
+		return policy
+	}
+	var buf [64]byte
+	if policy := client.policies[string(upperAppId(buf[:0], appId))]; nil != policy {
+		return policy
+	}
+	return client.defaultPolicy
+}
+
+// 是否需要按元数据或可用区过滤实例
+func (policy *appPolicy) filtering() bool {
+	return 0 < len(policy.config.MetadataFilters) || policy.config.PreferSameZone
+}
+
+// 实例元数据是否与配置的过滤条件全部匹配
+func (policy *appPolicy) matches(instance *core.Instance) bool {
+	for k, v := range policy.config.MetadataFilters {
+		if v != metadataString(instance, k) {
+			return false
+		}
+	}
+	return true
+}
+
+// 请求是否可以重试: 幂等请求或允许所有请求重试, 且请求体可以重放
+func (policy *appPolicy) retryable(req *http.Request) bool {
+	if nil != req.Body && http.NoBody != req.Body && nil == req.GetBody {
+		return false
+	}
+	if policy.config.OkToRetryOnAllOperations {
+		return true
+	}
+
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// 本次调用结果是否需要重试
+func (policy *appPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if nil != err {
+		return true
+	}
+	_, ok := policy.retryableStatusCodes[resp.StatusCode]
+	return ok
+}
+
+// 元数据中的字符串值
+func metadataString(instance *core.Instance, key string) string {
+	value, ok := instance.Metadata[key]
+	if !ok || nil == value {
+		return ""
+	}
+	if str, ok := value.(string); ok {
+		return str
+	}
+	return fmt.Sprint(value)
+}
+
+func isEmptyString(str string) bool {
+	return 0 == len(strings.TrimSpace(str))
+}
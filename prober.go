@@ -0,0 +1,221 @@
+package eureka
+
+import (
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+	"go.uber.org/atomic"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProbeState 实例的主动健康检查状态
+type ProbeState struct {
+	InstanceId          string    `json:"instanceId"`
+	HealthCheckUrl      string    `json:"healthCheckUrl"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastProbeTime       time.Time `json:"lastProbeTime"`
+	LastError           string    `json:"lastError,omitempty"`
+}
+
+// 主动健康检查
+// 只检查配置中开启且被调用过的应用, 检查失败的实例在本地视图中标记为不健康
+type healthProber struct {
+	// 开启检查的应用, 启动后只读
+	// key: appId
+	targets map[string]*probeTarget
+
+	mutex sync.RWMutex
+	// key: instanceId
+	states map[string]*ProbeState
+	// 不健康的实例数, 在 mutex 内修改, 选择实例时无锁读取
+	unhealthy atomic.Int64
+}
+
+type probeTarget struct {
+	// 是否被调用过
+	called atomic.Bool
+}
+
+func newHealthProber(cfg *config.HealthProbeConfig) *healthProber {
+	prober := &healthProber{
+		targets: make(map[string]*probeTarget),
+		states:  make(map[string]*ProbeState),
+	}
+	if !cfg.Enabled {
+		return prober
+	}
+
+	for _, appId := range cfg.Apps {
+		prober.targets[strings.ToUpper(appId)] = &probeTarget{}
+	}
+	return prober
+}
+
+// 记录应用被调用过, appId 不区分大小写
+func (prober *healthProber) touch(appId string) {
+	if 0 == len(prober.targets) {
+		return
+	}
+	var buf [64]byte
+	if target := prober.targets[string(upperAppId(buf[:0], appId))]; nil != target && !target.called.Load() {
+		target.called.Store(true)
+	}
+}
+
+// 实例是否被检查为不健康
+func (prober *healthProber) isUnhealthy(instance *core.Instance) bool {
+	if 0 == len(prober.targets) {
+		return false
+	}
+
+	prober.mutex.RLock()
+	defer prober.mutex.RUnlock()
+	state := prober.states[instance.InstanceId]
+	return nil != state && !state.Healthy
+}
+
+// 检查结果快照
+// key: appId
+func (prober *healthProber) snapshot(reg *registry) map[string][]ProbeState {
+	prober.mutex.RLock()
+	defer prober.mutex.RUnlock()
+
+	states := make(map[string][]ProbeState, len(prober.targets))
+	for appId := range prober.targets {
+		app := reg.servers[appId]
+		if nil == app {
+			continue
+		}
+		for _, s := range app.servers {
+			if state := prober.states[s.instance.InstanceId]; nil != state {
+				states[appId] = append(states[appId], *state)
+			}
+		}
+	}
+	return states
+}
+
+// 定时检查
+func (client *Client) probeHealth() {
+	cfg := &client.config.Load().ClientConfig.HealthProbe
+	if !cfg.Enabled || 0 == len(client.prober.targets) {
+		return
+	}
+
+	httpClient := &http.Client{Timeout: time.Duration(cfg.GetTimeoutSeconds()) * time.Second}
+	for {
+		client.probeOnce(httpClient, cfg)
+		time.Sleep(time.Duration(cfg.GetIntervalSeconds()) * time.Second)
+	}
+}
+
+// 检查一轮所有被调用过的应用的实例
+func (client *Client) probeOnce(httpClient *http.Client, cfg *config.HealthProbeConfig) {
+	reg := client.registry()
+	probed := make(map[string]struct{})
+	semaphore := make(chan struct{}, cfg.GetConcurrency())
+	var wg sync.WaitGroup
+
+	for appId, target := range client.prober.targets {
+		app := reg.servers[appId]
+		if !target.called.Load() || nil == app {
+			continue
+		}
+
+		for _, s := range app.servers {
+			instance := s.instance
+			probed[instance.InstanceId] = struct{}{}
+			healthCheckUrl := probeUrl(instance)
+			if 0 == len(healthCheckUrl) {
+				continue
+			}
+
+			semaphore <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-semaphore
+					wg.Done()
+				}()
+				client.recordProbe(instance, probe(httpClient, healthCheckUrl), cfg)
+			}()
+		}
+	}
+	wg.Wait()
+
+	//清理已下线实例的检查状态
+	client.prober.mutex.Lock()
+	defer client.prober.mutex.Unlock()
+	for instanceId, state := range client.prober.states {
+		if _, ok := probed[instanceId]; !ok {
+			if !state.Healthy {
+				client.prober.unhealthy.Dec()
+			}
+			delete(client.prober.states, instanceId)
+		}
+	}
+}
+
+// 启用https端口的实例优先检查 secureHealthCheckUrl
+func probeUrl(instance *core.Instance) string {
+	if portEnabled(instance.SecurePort) && 0 < len(instance.SecureHealthCheckUrl) {
+		return instance.SecureHealthCheckUrl
+	}
+	return instance.HealthCheckUrl
+}
+
+func (client *Client) recordProbe(instance *core.Instance, err error, cfg *config.HealthProbeConfig) {
+	client.prober.mutex.Lock()
+	defer client.prober.mutex.Unlock()
+
+	state := client.prober.states[instance.InstanceId]
+	if nil == state {
+		state = &ProbeState{InstanceId: instance.InstanceId, Healthy: true}
+		client.prober.states[instance.InstanceId] = state
+	}
+	state.HealthCheckUrl = probeUrl(instance)
+	state.LastProbeTime = time.Now()
+
+	if nil == err {
+		if !state.Healthy {
+			client.prober.unhealthy.Dec()
+			client.logger.Info(fmt.Sprintf("Instance %s of app %s is healthy again", instance.InstanceId, instance.App))
+		}
+		state.Healthy = true
+		state.ConsecutiveFailures = 0
+		state.LastError = ""
+		return
+	}
+
+	state.ConsecutiveFailures++
+	state.LastError = err.Error()
+	if state.Healthy && state.ConsecutiveFailures >= cfg.GetUnhealthyThreshold() {
+		state.Healthy = false
+		client.prober.unhealthy.Inc()
+		client.logger.Warn(fmt.Sprintf("Instance %s of app %s is unhealthy, err=%s", instance.InstanceId, instance.App, err.Error()))
+	}
+}
+
+// 请求健康检查地址, 2xx 视为健康
+func probe(httpClient *http.Client, healthCheckUrl string) error {
+	resp, err := httpClient.Get(healthCheckUrl)
+	if nil != err {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 获取主动健康检查状态
+// key: appId
+func (client *Client) ProbeStates() map[string][]ProbeState {
+	return client.prober.snapshot(client.registry())
+}
@@ -0,0 +1,80 @@
+package eureka
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"go.uber.org/atomic"
+)
+
+// 检查失败达到阈值的实例不会被选中, 恢复后重新可用
+func TestProbeMarksUnhealthy(t *testing.T) {
+	var healthy atomic.Bool
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if "/bad" == r.URL.Path && !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer health.Close()
+
+	bad := upInstance("DEMO", "demo-1", "10.0.0.1", 80)
+	bad.HealthCheckUrl = health.URL + "/bad"
+	good := upInstance("DEMO", "demo-2", "10.0.0.2", 80)
+	good.HealthCheckUrl = health.URL + "/ok"
+	stub := newEurekaStub(t, testApp("DEMO", bad, good))
+
+	probeConfig := config.HealthProbeConfig{Enabled: true, Apps: []string{"demo"}, UnhealthyThreshold: 2}
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.ClientConfig.HealthProbe = probeConfig
+	})
+	client.prober = newHealthProber(&probeConfig)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	//未被调用过的应用不检查
+	client.probeOnce(http.DefaultClient, &probeConfig)
+	if 0 != len(client.ProbeStates()) {
+		t.Fatalf("probed an app that was never called: %v", client.ProbeStates())
+	}
+
+	if _, err := client.GetNextServerFromEureka("DEMO"); nil != err {
+		t.Fatal(err)
+	}
+	client.probeOnce(http.DefaultClient, &probeConfig)
+	if client.prober.isUnhealthy(&bad) {
+		t.Fatal("unhealthy before reaching unhealthyThreshold")
+	}
+	client.probeOnce(http.DefaultClient, &probeConfig)
+	if !client.prober.isUnhealthy(&bad) || client.prober.isUnhealthy(&good) || 1 != client.prober.unhealthy.Load() {
+		t.Fatalf("got states %+v", client.ProbeStates())
+	}
+	for i := 0; i < 6; i++ {
+		instance, err := client.GetNextServerFromEureka("DEMO")
+		if nil != err || "demo-2" != instance.InstanceId {
+			t.Fatalf("got %s, %v, want demo-2", instanceIdOf(instance), err)
+		}
+	}
+
+	healthy.Store(true)
+	client.probeOnce(http.DefaultClient, &probeConfig)
+	if client.prober.isUnhealthy(&bad) || 0 != client.prober.unhealthy.Load() {
+		t.Fatal("still unhealthy after a successful probe")
+	}
+}
+
+// 启用https端口的实例优先检查 secureHealthCheckUrl
+func TestProbeUrlPrefersSecure(t *testing.T) {
+	instance := upInstance("DEMO", "demo-1", "10.0.0.1", 80)
+	instance.HealthCheckUrl = "http://10.0.0.1/health"
+	instance.SecureHealthCheckUrl = "https://10.0.0.1/health"
+	if got := probeUrl(&instance); instance.HealthCheckUrl != got {
+		t.Fatalf("got %s with https disabled", got)
+	}
+	instance.SecurePort.Enabled = "true"
+	if got := probeUrl(&instance); instance.SecureHealthCheckUrl != got {
+		t.Fatalf("got %s with https enabled", got)
+	}
+}
@@ -0,0 +1,276 @@
+package eureka
+
+import (
+	"github.com/phpdragon/go-eureka-client/core"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// 注册表快照
+// 快照构建完成后只读, 更新时复制一份新快照再通过 atomic.Pointer 整体替换,
+// 因此读操作无需加锁
+type registry struct {
+	// applications registry
+	// key: appId
+	// value: Application
+	apps map[string]*core.Application
+
+	// active servers registry
+	// key: appId
+	// value: appServers
+	servers map[string]*appServers
+
+	// vip registry, 多个应用可共用同一个vip
+	// key: lower(vipAddress)
+	// value: appServers
+	vips map[string]*appServers
+
+	// secure vip registry
+	// key: lower(secureVipAddress)
+	// value: appServers
+	svips map[string]*appServers
+
+	// 按vip向eureka查询得到的实例列表, 包含该vip下所有应用的实例,
+	// 重建vip索引时优先于由已缓存应用推导出的(可能不完整的)列表
+	// key: lower(vipAddress)
+	fetchedVips  map[string]*appServers
+	fetchedSvips map[string]*appServers
+}
+
+// 应用的有效实例, 预先构建好的稠密列表, 选择时按下标直接取值
+type appServers struct {
+	// 全部有效实例, ipPort 为实例的首选端口(启用https时为https端口)
+	servers []*server
+
+	// 按协议划分的有效实例
+	// key: int(http:0, https:1)
+	endpoints [2][]*server
+}
+
+// 按协议获取有效实例, anyKey 返回全部有效实例
+func (app *appServers) list(schemeKey int) []*server {
+	if anyKey == schemeKey {
+		return app.servers
+	}
+	return app.endpoints[schemeKey]
+}
+
+// 有效实例及其访问地址
+type server struct {
+	instance *core.Instance
+	// real ip:port
+	ipPort string
+}
+
+// 尚未抓取注册表时使用的空快照
+var emptyRegistry = newRegistry()
+
+func newRegistry() *registry {
+	return &registry{
+		apps:    make(map[string]*core.Application),
+		servers: make(map[string]*appServers),
+		vips:    make(map[string]*appServers),
+		svips:   make(map[string]*appServers),
+
+		fetchedVips:  make(map[string]*appServers),
+		fetchedSvips: make(map[string]*appServers),
+	}
+}
+
+// 根据全量应用列表构建快照
+func buildRegistry(filterOnlyUpInstances bool, apps []core.Application) *registry {
+	reg := newRegistry()
+	for i := range apps {
+		reg.putApp(filterOnlyUpInstances, &apps[i])
+	}
+	reg.indexVips(filterOnlyUpInstances)
+	return reg
+}
+
+// 复制当前快照并替换单个应用, 原快照保持不变
+func (reg *registry) withApp(filterOnlyUpInstances bool, app *core.Application) *registry {
+	dst := reg.copy()
+	dst.putApp(filterOnlyUpInstances, app)
+	dst.indexVips(filterOnlyUpInstances)
+	return dst
+}
+
+// 复制当前快照并替换单个vip的实例列表, 原快照保持不变
+func (reg *registry) withVip(filterOnlyUpInstances bool, secure bool, vipAddress string, instances []*core.Instance) *registry {
+	dst := reg.copy()
+	vip := strings.ToLower(vipAddress)
+	servers := newAppServers(filterOnlyUpInstances, instances)
+	if secure {
+		dst.svips[vip] = servers
+		dst.fetchedSvips[vip] = servers
+	} else {
+		dst.vips[vip] = servers
+		dst.fetchedVips[vip] = servers
+	}
+	return dst
+}
+
+// 浅拷贝快照, 各应用的 appServers 本身只读, 可以共享
+func (reg *registry) copy() *registry {
+	dst := &registry{
+		apps:    make(map[string]*core.Application, len(reg.apps)+1),
+		servers: make(map[string]*appServers, len(reg.servers)+1),
+		vips:    make(map[string]*appServers, len(reg.vips)+1),
+		svips:   make(map[string]*appServers, len(reg.svips)+1),
+
+		fetchedVips:  make(map[string]*appServers, len(reg.fetchedVips)+1),
+		fetchedSvips: make(map[string]*appServers, len(reg.fetchedSvips)+1),
+	}
+	for k, v := range reg.apps {
+		dst.apps[k] = v
+	}
+	for k, v := range reg.servers {
+		dst.servers[k] = v
+	}
+	for k, v := range reg.vips {
+		dst.vips[k] = v
+	}
+	for k, v := range reg.svips {
+		dst.svips[k] = v
+	}
+	for k, v := range reg.fetchedVips {
+		dst.fetchedVips[k] = v
+	}
+	for k, v := range reg.fetchedSvips {
+		dst.fetchedSvips[k] = v
+	}
+	return dst
+}
+
+// 仅在快照发布前调用
+func (reg *registry) putApp(filterOnlyUpInstances bool, app *core.Application) {
+	id := strings.ToUpper(app.Name)
+	reg.apps[id] = app
+	reg.servers[id] = newAppServers(filterOnlyUpInstances, instancePointers(app.Instances))
+}
+
+// 快照中全部有效实例的id
+func (reg *registry) instanceIds() map[string]struct{} {
+	instanceIds := make(map[string]struct{})
+	for _, servers := range []map[string]*appServers{reg.servers, reg.vips, reg.svips} {
+		for _, app := range servers {
+			for _, s := range app.servers {
+				instanceIds[s.instance.InstanceId] = struct{}{}
+			}
+		}
+	}
+	return instanceIds
+}
+
+// 应用在本地视图中的所有有效实例, 包括只通过vip查询得到的实例
+// key: instanceId
+func (reg *registry) appInstances(appId string) map[string]*core.Instance {
+	instances := make(map[string]*core.Instance)
+	for _, servers := range []map[string]*appServers{reg.servers, reg.vips, reg.svips} {
+		for _, app := range servers {
+			for _, s := range app.servers {
+				if strings.EqualFold(appId, s.instance.App) {
+					instances[s.instance.InstanceId] = s.instance
+				}
+			}
+		}
+	}
+	return instances
+}
+
+// 按 VipAddress / SecureVipAddress 重建全部应用的vip索引, 保留按vip查询得到的实例列表
+// 一个实例可声明多个以逗号分隔的vip
+func (reg *registry) indexVips(filterOnlyUpInstances bool) {
+	vips := make(map[string][]*core.Instance)
+	svips := make(map[string][]*core.Instance)
+	for _, app := range reg.apps {
+		for i := range app.Instances {
+			instance := &app.Instances[i]
+			for _, vip := range splitVipAddress(instance.VipAddress) {
+				vips[vip] = append(vips[vip], instance)
+			}
+			for _, svip := range splitVipAddress(instance.SecureVipAddress) {
+				svips[svip] = append(svips[svip], instance)
+			}
+		}
+	}
+
+	reg.vips = make(map[string]*appServers, len(vips))
+	for vip, instances := range vips {
+		reg.vips[vip] = newAppServers(filterOnlyUpInstances, instances)
+	}
+	reg.svips = make(map[string]*appServers, len(svips))
+	for svip, instances := range svips {
+		reg.svips[svip] = newAppServers(filterOnlyUpInstances, instances)
+	}
+
+	for vip, servers := range reg.fetchedVips {
+		reg.vips[vip] = servers
+	}
+	for svip, servers := range reg.fetchedSvips {
+		reg.svips[svip] = servers
+	}
+}
+
+func splitVipAddress(vipAddress string) []string {
+	if 0 == len(vipAddress) {
+		return nil
+	}
+
+	vips := make([]string, 0, 1)
+	for _, vip := range strings.Split(vipAddress, ",") {
+		vip = strings.ToLower(strings.TrimSpace(vip))
+		if 0 < len(vip) {
+			vips = append(vips, vip)
+		}
+	}
+	return vips
+}
+
+func instancePointers(instances []core.Instance) []*core.Instance {
+	pointers := make([]*core.Instance, len(instances))
+	for i := range instances {
+		pointers[i] = &instances[i]
+	}
+	return pointers
+}
+
+// 获取有效的实例和链接
+func newAppServers(filterOnlyUpInstances bool, instances []*core.Instance) *appServers {
+	app := &appServers{
+		servers: make([]*server, 0, len(instances)),
+	}
+
+	for _, instance := range instances {
+
+		if filterOnlyUpInstances && core.STATUS_UP != instance.Status {
+			continue
+		}
+
+		var httpServer, httpsServer *server
+		if portEnabled(instance.Port) {
+			httpServer = &server{instance: instance, ipPort: net.JoinHostPort(instance.IpAddr, strconv.Itoa(instance.Port.Port))}
+			app.endpoints[httpKey] = append(app.endpoints[httpKey], httpServer)
+		}
+		if portEnabled(instance.SecurePort) {
+			httpsServer = &server{instance: instance, ipPort: net.JoinHostPort(instance.IpAddr, strconv.Itoa(instance.SecurePort.Port))}
+			app.endpoints[httpsKey] = append(app.endpoints[httpsKey], httpsServer)
+		}
+
+		switch {
+		case nil != httpsServer:
+			app.servers = append(app.servers, httpsServer)
+		case nil != httpServer:
+			app.servers = append(app.servers, httpServer)
+		default:
+			app.servers = append(app.servers, &server{instance: instance, ipPort: instance.IpAddr})
+		}
+	}
+
+	return app
+}
+
+func portEnabled(port *core.Port) bool {
+	return nil != port && "true" == port.Enabled
+}
@@ -0,0 +1,279 @@
+package eureka
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+// 并发刷新注册表与查询, 需配合 go test -race 运行
+func TestConcurrentFetchAndLookup(t *testing.T) {
+	stub := newEurekaStub(t,
+		testApp("DEMO", upInstance("DEMO", "demo-1", "10.0.0.1", 80), upInstance("DEMO", "demo-2", "10.0.0.2", 80)),
+		testApp("OTHER", upInstance("OTHER", "other-1", "10.0.1.1", 80)))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := client.fetchRegistry(); nil != err {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if _, err := client.GetNextServerFromEureka("DEMO"); nil != err {
+					t.Error(err)
+					return
+				}
+				if _, err := client.GetRealHttpUrl("http://other/ping"); nil != err {
+					t.Error(err)
+					return
+				}
+				for _, app := range client.GetApplications() {
+					app.Instances = nil
+				}
+				client.GetInstances()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// 刷新注册表替换整个快照, 已取得的旧快照不受影响
+func TestFetchRegistrySwapsSnapshot(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO", upInstance("DEMO", "demo-1", "10.0.0.1", 80)))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	old := client.registry()
+
+	stub.setApps(testApp("DEMO", upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	if got := old.servers["DEMO"].servers[0].instance.InstanceId; "demo-1" != got {
+		t.Fatalf("old snapshot changed, got %s", got)
+	}
+	instance, err := client.GetNextServerFromEureka("DEMO")
+	if nil != err || "demo-2" != instance.InstanceId {
+		t.Fatalf("got %s, %v, want demo-2", instanceIdOf(instance), err)
+	}
+}
+
+// 返回给调用方的应用列表是副本
+func TestGetApplicationsReturnsCopies(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO", upInstance("DEMO", "demo-1", "10.0.0.1", 80)))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	apps := client.GetApplications()
+	apps["DEMO"].Instances[0].Status = core.STATUS_DOWN
+	client.GetInstances()["DEMO"][0].IpAddr = "changed"
+
+	instance, err := client.GetNextServerFromEureka("DEMO")
+	if nil != err || core.STATUS_UP != instance.Status || "10.0.0.1" != instance.IpAddr {
+		t.Fatalf("registry changed through copies: %+v, %v", instance, err)
+	}
+}
+
+// 只保留 UP 的实例, 按端口启用情况分为 http 和 https 两组
+func TestNewAppServersFiltersAndSplitsEndpoints(t *testing.T) {
+	httpOnly := upInstance("DEMO", "demo-1", "10.0.0.1", 80)
+	both := upInstance("DEMO", "demo-2", "10.0.0.2", 80)
+	both.SecurePort = &core.Port{Port: 8443, Enabled: "true"}
+	down := upInstance("DEMO", "demo-3", "10.0.0.3", 80)
+	down.Status = core.STATUS_DOWN
+
+	app := newAppServers(true, []*core.Instance{&httpOnly, &both, &down})
+	if 2 != len(app.servers) {
+		t.Fatalf("got %d servers, want 2", len(app.servers))
+	}
+	if "10.0.0.2:8443" != app.servers[1].ipPort {
+		t.Fatalf("got %s, want https endpoint for instance with both ports", app.servers[1].ipPort)
+	}
+	if 2 != len(app.endpoints[httpKey]) || 1 != len(app.endpoints[httpsKey]) {
+		t.Fatalf("got %d http and %d https endpoints, want 2 and 1", len(app.endpoints[httpKey]), len(app.endpoints[httpsKey]))
+	}
+	if "10.0.0.2:8443" != app.endpoints[httpsKey][0].ipPort {
+		t.Fatalf("got https endpoint %s", app.endpoints[httpsKey][0].ipPort)
+	}
+
+	if all := newAppServers(false, []*core.Instance{&httpOnly, &down}); 2 != len(all.servers) {
+		t.Fatalf("got %d servers without filtering, want 2", len(all.servers))
+	}
+}
+
+// https 地址只使用启用了 https 端口的实例
+func TestGetRealHttpUrlByScheme(t *testing.T) {
+	secure := upInstance("DEMO", "demo-2", "10.0.0.2", 80)
+	secure.SecurePort = &core.Port{Port: 8443, Enabled: "true"}
+	stub := newEurekaStub(t, testApp("DEMO", upInstance("DEMO", "demo-1", "10.0.0.1", 80), secure))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		url, err := client.GetRealHttpUrl("https://demo/ping")
+		if nil != err || "https://10.0.0.2:8443/ping" != url {
+			t.Fatalf("got %s, %v", url, err)
+		}
+	}
+}
+
+// 只替换url中的主机部分, 路径及参数中的服务名保持不变
+func TestGetRealHttpUrlReplacesHostOnly(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO", upInstance("DEMO", "demo-1", "10.0.0.1", 80)))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	url, err := client.GetRealHttpUrl("http://demo/api/demo?x=demo")
+	if nil != err || "http://10.0.0.1:80/api/demo?x=demo" != url {
+		t.Fatalf("got %s, %v", url, err)
+	}
+}
+
+// vip下的实例均被摘除时仍返回实例, 均不符合过滤条件时返回错误
+func TestGetNextServerByVipWithoutCandidates(t *testing.T) {
+	a := upInstance("DEMO", "demo-1", "10.0.0.1", 80)
+	a.VipAddress = "shared"
+	b := upInstance("DEMO", "demo-2", "10.0.0.2", 80)
+	b.VipAddress = "shared"
+	stub := newEurekaStub(t, testApp("DEMO", a, b))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	until := time.Now().Add(time.Minute).UnixNano()
+	for _, id := range []string{"demo-1", "demo-2"} {
+		client.outliers.getOrCreate(id).ejectedUntil.Store(until)
+	}
+	client.outliers.ejectedUntil.Store(until)
+	if instance, err := client.GetNextServerByVip("shared", false); nil != err || 0 == len(instance.InstanceId) {
+		t.Fatalf("got %s, %v, want an ejected instance", instanceIdOf(instance), err)
+	}
+
+	client.defaultPolicy = &appPolicy{
+		config:   config.ClientPolicy{MetadataFilters: map[string]string{"version": "v2"}},
+		balancer: client.balancer,
+	}
+	if _, err := client.GetNextServerByVip("shared", false); nil == err {
+		t.Fatal("want error when no instance matches")
+	}
+}
+
+// 轮询均匀地选择各有效实例
+func TestRoundRobinIsEven(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO",
+		upInstance("DEMO", "demo-1", "10.0.0.1", 80),
+		upInstance("DEMO", "demo-2", "10.0.0.2", 80),
+		upInstance("DEMO", "demo-3", "10.0.0.3", 80)))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		instance, err := client.GetNextServerFromEureka("DEMO")
+		if nil != err {
+			t.Fatal(err)
+		}
+		counts[instance.InstanceId]++
+	}
+	for _, id := range []string{"demo-1", "demo-2", "demo-3"} {
+		if 10 != counts[id] {
+			t.Fatalf("got %v, want 10 each", counts)
+		}
+	}
+}
+
+// 所有实例均可用时选择实例不分配内存
+func TestGetNextServerFromEurekaDoesNotAllocate(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO",
+		upInstance("DEMO", "demo-1", "10.0.0.1", 80),
+		upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+	client := newTestClient(t, stub, nil)
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = client.GetNextServerFromEureka("DEMO")
+	})
+	if 0 != allocs {
+		t.Fatalf("got %v allocs per call, want 0", allocs)
+	}
+}
+
+// 按需加载应用时保留已按vip查询得到的实例列表
+func TestWithAppKeepsFetchedVips(t *testing.T) {
+	demo := upInstance("DEMO", "demo-1", "10.0.0.1", 80)
+	demo.VipAddress = "shared"
+	other := upInstance("OTHER", "other-1", "10.0.1.1", 80)
+	other.VipAddress = "shared"
+	stub := newEurekaStub(t, testApp("DEMO", demo), testApp("OTHER", other))
+	client := newTestClient(t, stub, nil)
+
+	instances, err := client.GetInstancesByVip("shared", false)
+	if nil != err || 2 != len(instances) {
+		t.Fatalf("got %d instances, %v, want 2", len(instances), err)
+	}
+	if _, err := client.GetNextServerFromEureka("DEMO"); nil != err {
+		t.Fatal(err)
+	}
+
+	instances, err = client.GetInstancesByVip("shared", false)
+	if nil != err || 2 != len(instances) {
+		t.Fatalf("got %d instances after loading DEMO, %v, want 2", len(instances), err)
+	}
+	if n := stub.count("GET /vips/"); 1 != n {
+		t.Fatalf("got %d vip queries, want 1", n)
+	}
+}
+
+// 小写的应用名及排除实例的查询同样不分配内存
+func TestLookupDoesNotAllocate(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO",
+		upInstance("DEMO", "demo-1", "10.0.0.1", 80),
+		upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.Clients = map[string]config.ClientPolicy{"demo": {LoadBalancer: config.LoadBalancerRandom}}
+	})
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	lookups := map[string]func(){
+		"lowercase": func() { _, _ = client.GetNextServerFromEureka("demo") },
+		"excluding": func() { _, _ = client.GetNextServerExcluding("DEMO") },
+	}
+	for name, lookup := range lookups {
+		if allocs := testing.AllocsPerRun(100, lookup); 0 != allocs {
+			t.Errorf("%s: got %v allocs per call, want 0", name, allocs)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package eureka
+
+import (
+	"fmt"
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 定时检查配置文件, 修改后重新加载并应用可以热更新的配置:
+// eureka.serviceUrl.defaultZone、eureka.instance.metadata、eureka.instance.leaseInfo、eureka.client.registryFetchIntervalSeconds
+// 其他配置的修改需要重启, 只记录日志
+func (client *Client) watchConfig() {
+	if 0 >= client.config.Load().ClientConfig.ConfigReloadIntervalSeconds || 0 == len(client.configPath) {
+		return
+	}
+
+	modTime, size := fileVersion(client.configPath)
+	for {
+		interval := client.config.Load().ClientConfig.ConfigReloadIntervalSeconds
+		if 0 >= interval {
+			client.logger.Info("Config reload is disabled")
+			return
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+
+		newModTime, newSize := fileVersion(client.configPath)
+		if newModTime.Equal(modTime) && newSize == size {
+			continue
+		}
+		modTime, size = newModTime, newSize
+
+		client.reloadConfig()
+	}
+}
+
+// 配置文件的修改时间和大小, 文件不存在时为零值
+func fileVersion(path string) (time.Time, int64) {
+	info, err := os.Stat(path)
+	if nil != err {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+// 重新加载配置文件, 加载或校验失败时保留原配置
+func (client *Client) reloadConfig() {
+	newConfig, err := config.LoadConfig(client.configPath, true)
+	if nil != err {
+		client.logger.Error(fmt.Sprintf("Failed to reload config %s, err=%s", client.configPath, err.Error()))
+		return
+	}
+
+	oldConfig := client.config.Load()
+	applied := *oldConfig
+
+	//其他配置保持不变
+	for _, name := range changedFields("eureka", reflect.ValueOf(reloadable(*oldConfig, newConfig)), reflect.ValueOf(*newConfig)) {
+		client.logger.Warn(fmt.Sprintf("Config %s changed but requires a restart, ignored", name))
+	}
+
+	if oldConfig.ServiceURL.DefaultZone != newConfig.ServiceURL.DefaultZone {
+		api, err := pickEurekaServerApi(newConfig.ServiceURL.DefaultZone)
+		if nil != err {
+			client.logger.Error(fmt.Sprintf("Failed to apply eureka.serviceUrl.defaultZone, err=%s", err.Error()))
+		} else {
+			applied.ServiceURL = newConfig.ServiceURL
+			client.apiClient.Store(api)
+			client.logger.Info(fmt.Sprintf("Apply eureka.serviceUrl.defaultZone=%s, use %s", newConfig.ServiceURL.DefaultZone, api.BaseUrl))
+		}
+	}
+
+	if !reflect.DeepEqual(oldConfig.InstanceConfig.Metadata, newConfig.InstanceConfig.Metadata) {
+		if err := client.updateMetadata(newConfig.InstanceMetadata()); nil != err {
+			client.logger.Error(fmt.Sprintf("Failed to apply eureka.instance.metadata, err=%s", err.Error()))
+		} else {
+			applied.InstanceConfig.Metadata = newConfig.InstanceConfig.Metadata
+			client.logger.Info("Apply eureka.instance.metadata")
+		}
+	}
+
+	if oldConfig.InstanceConfig.LeaseInfo != newConfig.InstanceConfig.LeaseInfo {
+		applied.InstanceConfig.LeaseInfo = newConfig.InstanceConfig.LeaseInfo
+		client.updateLeaseInfo(newConfig)
+		client.logger.Info(fmt.Sprintf("Apply eureka.instance.leaseInfo, renewalIntervalInSecs=%d, durationInSecs=%d",
+			newConfig.InstanceConfig.LeaseInfo.RenewalIntervalInSecs, newConfig.InstanceConfig.LeaseInfo.DurationInSecs))
+	}
+
+	if oldConfig.ClientConfig.RegistryFetchIntervalSeconds != newConfig.ClientConfig.RegistryFetchIntervalSeconds {
+		applied.ClientConfig.RegistryFetchIntervalSeconds = newConfig.ClientConfig.RegistryFetchIntervalSeconds
+		client.logger.Info(fmt.Sprintf("Apply eureka.client.registryFetchIntervalSeconds=%d", newConfig.ClientConfig.RegistryFetchIntervalSeconds))
+	}
+	applied.ClientConfig.ConfigReloadIntervalSeconds = newConfig.ClientConfig.ConfigReloadIntervalSeconds
+
+	client.config.Store(&applied)
+}
+
+// 原配置替换为新配置中可以热更新的部分, 与新配置比较即为需要重启才能生效的修改
+func reloadable(oldConfig config.Config, newConfig *config.Config) config.Config {
+	oldConfig.ServiceURL = newConfig.ServiceURL
+	oldConfig.InstanceConfig.Metadata = newConfig.InstanceConfig.Metadata
+	oldConfig.InstanceConfig.LeaseInfo = newConfig.InstanceConfig.LeaseInfo
+	oldConfig.ClientConfig.RegistryFetchIntervalSeconds = newConfig.ClientConfig.RegistryFetchIntervalSeconds
+	oldConfig.ClientConfig.ConfigReloadIntervalSeconds = newConfig.ClientConfig.ConfigReloadIntervalSeconds
+	return oldConfig
+}
+
+// 比较两个配置, 返回修改了的配置项, 如 eureka.instance.appName
+func changedFields(name string, oldValue reflect.Value, newValue reflect.Value) []string {
+	if reflect.Struct != oldValue.Kind() {
+		if reflect.DeepEqual(oldValue.Interface(), newValue.Interface()) {
+			return nil
+		}
+		return []string{name}
+	}
+
+	var changed []string
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if 0 == len(tag) || "-" == tag {
+			tag = field.Name
+		}
+		changed = append(changed, changedFields(name+"."+tag, oldValue.Field(i), newValue.Field(i))...)
+	}
+	return changed
+}
+
+// 向注册中心更新实例的元数据
+// 元数据接口只能新增或修改元数据项, 删除了元数据项时重新注册整个实例
+func (client *Client) updateMetadata(metadata map[string]interface{}) error {
+	current := client.instance.Load()
+	if client.config.Load().ClientConfig.RegisterWithEureka && client.isRunning() {
+		if hasRemovedKeys(current.Metadata, metadata) {
+			updated := current.Clone()
+			updated.Metadata = metadata
+			if err := client.apiClient.Load().RegisterInstance(updated.App, updated); nil != err {
+				return err
+			}
+		} else {
+			values := make(map[string]string, len(metadata))
+			for k, v := range metadata {
+				values[k] = fmt.Sprint(v)
+			}
+			if err := client.apiClient.Load().UpdateMeta(current.App, current.InstanceId, values); nil != err {
+				return err
+			}
+		}
+	}
+
+	client.updateInstance(func(instance *core.Instance) {
+		instance.Metadata = metadata
+	})
+	return nil
+}
+
+// newMetadata 中是否删除了 oldMetadata 的元数据项
+func hasRemovedKeys(oldMetadata map[string]interface{}, newMetadata map[string]interface{}) bool {
+	for k := range oldMetadata {
+		if _, ok := newMetadata[k]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 更新实例的租约信息, 心跳间隔在下次心跳后生效, 租约过期时间在重新注册后生效
+func (client *Client) updateLeaseInfo(newConfig *config.Config) {
+	instance := client.updateInstance(func(instance *core.Instance) {
+		instance.LeaseInfo.RenewalIntervalInSecs = newConfig.InstanceConfig.LeaseInfo.RenewalIntervalInSecs
+		instance.LeaseInfo.DurationInSecs = newConfig.InstanceConfig.LeaseInfo.DurationInSecs
+	})
+
+	if !client.config.Load().ClientConfig.RegisterWithEureka || !client.isRunning() {
+		return
+	}
+	if err := client.apiClient.Load().RegisterInstance(instance.App, instance); nil != err {
+		client.logger.Error(fmt.Sprintf("Failed to re-register with new leaseInfo, err=%s", err.Error()))
+	}
+}
@@ -0,0 +1,159 @@
+package eureka
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+// 已注册的客户端, 只用于同步元数据, 不启动后台任务
+func newRegisteredTestClient(t *testing.T, stub *eurekaStub) *Client {
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.ClientConfig.RegisterWithEureka = true
+		cfg.Clients = map[string]config.ClientPolicy{"demo": {PreferSameZone: true}}
+	})
+	client.Running = true
+	client.updateInstance(func(instance *core.Instance) {
+		instance.Metadata = map[string]interface{}{metadataZoneKey: "a", "owner": "team"}
+	})
+	return client
+}
+
+// 元数据值经过转义后通过元数据接口更新
+func TestUpdateMetadataEscapesValues(t *testing.T) {
+	stub := newEurekaStub(t)
+	client := newRegisteredTestClient(t, stub)
+
+	metadata := map[string]interface{}{metadataZoneKey: "a", "owner": "team a&b=c"}
+	if err := client.updateMetadata(metadata); nil != err {
+		t.Fatal(err)
+	}
+
+	stub.mutex.Lock()
+	requests := append([]string(nil), stub.requests...)
+	stub.mutex.Unlock()
+	if 1 != len(requests) || !strings.HasPrefix(requests[0], "PUT /apps/SELF/self/metadata?") {
+		t.Fatalf("got requests %v, want one metadata update", requests)
+	}
+	query, err := url.ParseQuery(strings.SplitN(requests[0], "?", 2)[1])
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "team a&b=c" != query.Get("owner") || "a" != query.Get(metadataZoneKey) || 2 != len(query) {
+		t.Fatalf("got query %v", query)
+	}
+}
+
+// 删除了元数据项时重新注册整个实例
+func TestUpdateMetadataReRegistersOnRemovedKeys(t *testing.T) {
+	stub := newEurekaStub(t)
+	client := newRegisteredTestClient(t, stub)
+
+	if err := client.updateMetadata(map[string]interface{}{metadataZoneKey: "a"}); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != stub.count("POST /apps/SELF") || 0 != stub.count("PUT ") {
+		t.Fatalf("got requests %v, want a re-registration", stub.requests)
+	}
+	if _, ok := client.instance.Load().Metadata["owner"]; ok {
+		t.Fatal("removed metadata key still present on the local instance")
+	}
+}
+
+// 修改元数据替换整个实例, 已取得的旧实例不受影响
+func TestUpdateMetadataSwapsInstance(t *testing.T) {
+	stub := newEurekaStub(t)
+	client := newRegisteredTestClient(t, stub)
+	old := client.instance.Load()
+
+	if err := client.updateMetadata(map[string]interface{}{metadataZoneKey: "b", "owner": "team"}); nil != err {
+		t.Fatal(err)
+	}
+	if "a" != old.Metadata[metadataZoneKey] {
+		t.Fatalf("old instance changed, got zone %v", old.Metadata[metadataZoneKey])
+	}
+	if "b" != client.zone() {
+		t.Fatalf("got zone %s, want b", client.zone())
+	}
+}
+
+// 同步元数据失败时保留本地元数据
+func TestUpdateMetadataKeepsLocalOnError(t *testing.T) {
+	stub := newEurekaStub(t)
+	client := newRegisteredTestClient(t, stub)
+	stub.server.Close()
+
+	if err := client.updateMetadata(map[string]interface{}{metadataZoneKey: "b", "owner": "team"}); nil == err {
+		t.Fatal("want error when eureka server is unavailable")
+	}
+	if "a" != client.zone() {
+		t.Fatalf("got zone %s, want a", client.zone())
+	}
+}
+
+// 选择实例时并发修改元数据, 需配合 go test -race 运行
+func TestConcurrentUpdateMetadataAndLookup(t *testing.T) {
+	a := upInstance("DEMO", "demo-1", "10.0.0.1", 80)
+	a.Metadata = map[string]interface{}{metadataZoneKey: "a"}
+	b := upInstance("DEMO", "demo-2", "10.0.0.2", 80)
+	b.Metadata = map[string]interface{}{metadataZoneKey: "b"}
+	stub := newEurekaStub(t, testApp("DEMO", a, b))
+	client := newRegisteredTestClient(t, stub)
+	client.Running = false
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			zone := []string{"a", "b"}[i%2]
+			if err := client.updateMetadata(map[string]interface{}{metadataZoneKey: zone, "owner": "team"}); nil != err {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if _, err := client.GetNextServerFromEureka("DEMO"); nil != err {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// 启动或注销时并发同步元数据, 需配合 go test -race 运行
+func TestConcurrentRunningAndUpdateMetadata(t *testing.T) {
+	stub := newEurekaStub(t)
+	client := newRegisteredTestClient(t, stub)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			client.mutex.Lock()
+			client.Running = 0 == i%2
+			client.mutex.Unlock()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := client.updateMetadata(map[string]interface{}{metadataZoneKey: "a", "owner": "team"}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,187 @@
+package eureka
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+	"github.com/phpdragon/go-eureka-client/logger"
+	"go.uber.org/atomic"
+)
+
+// 模拟 eureka 服务端, 按 REST 接口返回 apps 中的应用
+type eurekaStub struct {
+	server *httptest.Server
+
+	mutex sync.Mutex
+	apps  []core.Application
+	// 收到的请求, 如 "GET /apps/DEMO"
+	requests []string
+}
+
+func newEurekaStub(t *testing.T, apps ...core.Application) *eurekaStub {
+	stub := &eurekaStub{apps: apps}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.serveHTTP))
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+func (stub *eurekaStub) setApps(apps ...core.Application) {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	stub.apps = apps
+}
+
+// 收到的与 prefix 匹配的请求数
+func (stub *eurekaStub) count(prefix string) int {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	n := 0
+	for _, request := range stub.requests {
+		if strings.HasPrefix(request, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func (stub *eurekaStub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	stub.mutex.Lock()
+	stub.requests = append(stub.requests, r.Method+" "+r.URL.RequestURI())
+	apps := stub.apps
+	stub.mutex.Unlock()
+
+	//注册返回 204, 心跳及修改状态、元数据返回 200
+	if http.MethodPut == r.Method {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if http.MethodGet != r.Method {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case "apps" == parts[0] && 1 == len(parts):
+		_ = json.NewEncoder(w).Encode(core.EurekaApps{Applications: core.Applications{Applications: apps}})
+		return
+	case "apps" == parts[0] && 2 == len(parts):
+		for _, app := range apps {
+			if strings.EqualFold(app.Name, parts[1]) {
+				_ = json.NewEncoder(w).Encode(core.EurekaApp{Application: app})
+				return
+			}
+		}
+	case ("vips" == parts[0] || "svips" == parts[0]) && 2 == len(parts):
+		var matched []core.Application
+		for _, app := range apps {
+			var instances []core.Instance
+			for _, instance := range app.Instances {
+				vipAddress := instance.VipAddress
+				if "svips" == parts[0] {
+					vipAddress = instance.SecureVipAddress
+				}
+				for _, vip := range splitVipAddress(vipAddress) {
+					if strings.EqualFold(vip, parts[1]) {
+						instances = append(instances, instance)
+						break
+					}
+				}
+			}
+			if 0 < len(instances) {
+				matched = append(matched, core.Application{Name: app.Name, Instances: instances})
+			}
+		}
+		if 0 < len(matched) {
+			_ = json.NewEncoder(w).Encode(core.EurekaApps{Applications: core.Applications{Applications: matched}})
+			return
+		}
+	case "instances" == parts[0] && 2 == len(parts):
+		for _, app := range apps {
+			for _, instance := range app.Instances {
+				if instance.InstanceId == parts[1] {
+					_ = json.NewEncoder(w).Encode(core.EurekaInstance{Instance: instance})
+					return
+				}
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// 连接 stub 的客户端, 未启动注册、心跳等后台任务
+func newTestClient(t *testing.T, stub *eurekaStub, configure func(cfg *config.Config)) *Client {
+	cfg := &config.Config{}
+	cfg.ServiceURL.DefaultZone = stub.server.URL
+	cfg.ClientConfig.FilterOnlyUpInstances = true
+	if nil != configure {
+		configure(cfg)
+	}
+
+	client := &Client{
+		autoIncr: atomic.NewInt64(0),
+		logger:   logger.NewLogAgent(nil),
+		loader:   newAppLoader(),
+		outliers: newOutlierDetector(),
+		prober:   newHealthProber(&cfg.ClientConfig.HealthProbe),
+		hedge:    newHedgeBudget(cfg.ClientConfig.GetHedgeBudgetPercent()),
+	}
+	client.config.Store(cfg)
+	client.instance.Store(&core.Instance{InstanceId: "self", App: "SELF", HostName: "self", IpAddr: "127.0.0.1",
+		Port: &core.Port{Port: 8080, Enabled: "true"}, SecurePort: &core.Port{Port: 443, Enabled: "false"},
+		LeaseInfo: &core.LeaseInfo{RenewalIntervalInSecs: 30, DurationInSecs: 90}})
+	client.apiClient.Store(core.NewEurekaServerApi(stub.server.URL))
+
+	var err error
+	if client.balancer, err = newLoadBalancer(cfg.ClientConfig.GetLoadBalancer(), client); nil != err {
+		t.Fatal(err)
+	}
+	if client.policies, err = client.newPolicies(); nil != err {
+		t.Fatal(err)
+	}
+	client.defaultPolicy, _ = client.newPolicy(config.ClientPolicy{})
+	return client
+}
+
+// 状态为 UP 的 http 实例
+func upInstance(app string, instanceId string, ip string, port int) core.Instance {
+	return core.Instance{
+		InstanceId: instanceId,
+		App:        app,
+		HostName:   instanceId,
+		IpAddr:     ip,
+		Status:     core.STATUS_UP,
+		VipAddress: strings.ToLower(app),
+		Port:       &core.Port{Port: port, Enabled: "true"},
+		SecurePort: &core.Port{Port: 443, Enabled: "false"},
+	}
+}
+
+func testApp(name string, instances ...core.Instance) core.Application {
+	return core.Application{Name: name, Instances: instances}
+}
+
+// 指向 httptest 服务的实例
+func serverInstance(t *testing.T, app string, instanceId string, server *httptest.Server) core.Instance {
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	portNum, _ := strconv.Atoi(port)
+	return upInstance(app, instanceId, host, portNum)
+}
+
+func instanceIdOf(instance *core.Instance) string {
+	if nil == instance {
+		return ""
+	}
+	return instance.InstanceId
+}
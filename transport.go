@@ -0,0 +1,234 @@
+package eureka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Transport 基于服务发现的 http.RoundTripper
+// 请求url的host为应用名, 如 http://DEMO-SERVICE/action, 发送前替换为实例的真实ip:port,
+// 按 eureka.clients 中应用的调用策略设置超时和重试, 并将调用结果上报给 Client.Report 用于摘除故障实例
+type Transport struct {
+	client *Client
+
+	// 实际发送请求的 RoundTripper, 为nil时使用 http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// NewTransport 创建基于服务发现的 http.RoundTripper, base 为nil时使用 http.DefaultTransport
+func (client *Client) NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{client: client, Base: base}
+}
+
+// RoundTrip 同一实例上最多重试 maxAutoRetries 次, 之后换一个未尝试过的实例,
+// 最多换 maxAutoRetriesNextServer 次; 只有幂等请求(或开启 okToRetryOnAllOperations)才会重试
+// 超过应用的并发请求数或每秒请求数时立即返回 *RejectedError;
+// 开启对冲时幂等请求超过对冲延迟未返回会向另一个实例再发一次, 取先返回的结果
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	appId := req.URL.Hostname()
+	policy := transport.client.policy(appId)
+
+	head := policy.bulkhead
+	if nil == head {
+		return transport.roundTrip(req, appId, policy)
+	}
+
+	if err := head.acquire(appId); nil != err {
+		closeRequestBody(req)
+		return nil, err
+	}
+	resp, err := transport.roundTrip(req, appId, policy)
+	return onResponseDone(resp, err, head.release)
+}
+
+func (transport *Transport) roundTrip(req *http.Request, appId string, policy *appPolicy) (*http.Response, error) {
+	schemeKey := httpKey
+	if "https" == req.URL.Scheme {
+		schemeKey = httpsKey
+	}
+
+	picker := transport.client.newPicker(appId, schemeKey)
+	retryable := policy.retryable(req)
+	maxNextServers := policy.config.GetMaxAutoRetriesNextServer()
+
+	var resp *http.Response
+	var err error
+	for next := 0; next <= maxNextServers; next++ {
+		target, pickErr := transport.pick(picker, policy, appId)
+		if nil != pickErr {
+			if 0 == next {
+				closeRequestBody(req)
+				return nil, pickErr
+			}
+			//没有其他实例可以重试, 返回最后一次的结果
+			return resp, err
+		}
+		if nil != resp {
+			discardResponse(resp)
+		}
+
+		if 0 == next && retryable && nil != policy.latencies {
+			target, resp, err = transport.sendHedged(req, picker, target, policy, appId)
+		} else {
+			resp, err = transport.sendWithRetries(req, target, policy, 0 == next, retryable)
+		}
+		recordChosenInstance(req.Context(), target.instance)
+		if nil != policy.bulkhead {
+			resp, err = onResponseDone(resp, err, func() {
+				policy.bulkhead.releaseInstance(target.instance.InstanceId)
+			})
+		}
+		if !retryable || !policy.shouldRetry(resp, err) || nil != req.Context().Err() {
+			return resp, err
+		}
+	}
+	return resp, err
+}
+
+// 在同一实例上发送请求, 需要重试时最多重试 maxAutoRetries 次
+func (transport *Transport) sendWithRetries(req *http.Request, target *server, policy *appPolicy, first bool, retryable bool) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	for retry := 0; retry <= policy.config.GetMaxAutoRetries(); retry++ {
+		if nil != resp {
+			discardResponse(resp)
+		}
+		if 0 < retry {
+			//选中实例时已开始首次调用, 每次重试都会上报结果
+			transport.client.begin(target.instance)
+		}
+
+		resp, err = transport.send(req.Context(), req, target, policy, first && 0 == retry)
+		if !retryable || !policy.shouldRetry(resp, err) || nil != req.Context().Err() {
+			break
+		}
+	}
+	return resp, err
+}
+
+// 选出下一个未尝试过的实例并开始调用, 跳过并发请求数已满的实例
+func (transport *Transport) pick(picker *Picker, policy *appPolicy, appId string) (*server, error) {
+	full := false
+	for {
+		target, err := picker.next()
+		if nil != err {
+			if full {
+				return nil, &RejectedError{AppId: appId, Reason: RejectReasonConcurrency}
+			}
+			return nil, err
+		}
+		transport.client.begin(target.instance)
+		if nil == policy.bulkhead || policy.bulkhead.acquireInstance(target.instance.InstanceId) {
+			return target, nil
+		}
+		//跳过的实例不会发送请求
+		transport.client.abandon(target.instance)
+		full = true
+	}
+}
+
+// 向选中的实例发送一次请求并上报结果, ctx 为 req.Context() 或其派生的上下文
+func (transport *Transport) send(ctx context.Context, req *http.Request, target *server, policy *appPolicy, first bool) (*http.Response, error) {
+	cancel := context.CancelFunc(nil)
+	if 0 < policy.config.ReadTimeoutMs {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(policy.config.ReadTimeoutMs)*time.Millisecond)
+	}
+
+	//不修改调用方的请求对象, 重试时重新获取请求体
+	outReq := req.Clone(ctx)
+	outReq.URL.Host = target.ipPort
+	outReq.Host = ""
+	if !first && nil != req.GetBody {
+		body, err := req.GetBody()
+		if nil != err {
+			transport.client.abandon(target.instance)
+			if nil != cancel {
+				cancel()
+			}
+			return nil, err
+		}
+		outReq.Body = body
+	}
+
+	start := time.Now()
+	resp, err := transport.base(policy).RoundTrip(outReq)
+	latency := time.Since(start)
+	if nil != err && errors.Is(context.Cause(ctx), errHedgeLost) {
+		//被取消的对冲请求不代表实例故障
+		transport.client.abandon(target.instance)
+	} else {
+		err := responseError(resp, err)
+		transport.client.Report(target.instance, err, latency)
+		if nil == err && nil != policy.latencies {
+			policy.latencies.record(latency)
+		}
+	}
+
+	if nil != cancel {
+		//读取完响应体后才取消超时
+		return onResponseDone(resp, err, cancel)
+	}
+	return resp, err
+}
+
+func (transport *Transport) base(policy *appPolicy) http.RoundTripper {
+	if nil != transport.Base {
+		return transport.Base
+	}
+	if nil != policy.transport {
+		return policy.transport
+	}
+	return http.DefaultTransport
+}
+
+// 请求结束(出错或响应体关闭)时执行回调
+func onResponseDone(resp *http.Response, err error, done func()) (*http.Response, error) {
+	if nil != err || nil == resp {
+		done()
+		return resp, err
+	}
+	resp.Body = &closeNotifier{ReadCloser: resp.Body, onClose: done}
+	return resp, err
+}
+
+// 关闭响应体时执行回调, 只执行一次
+type closeNotifier struct {
+	io.ReadCloser
+	once    sync.Once
+	onClose func()
+}
+
+func (body *closeNotifier) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(body.onClose)
+	return err
+}
+
+// 将5xx响应视为实例调用失败
+func responseError(resp *http.Response, err error) error {
+	if nil != err {
+		return err
+	}
+	if http.StatusInternalServerError <= resp.StatusCode {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 丢弃需要重试的响应, 读完响应体以复用连接
+func discardResponse(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+}
+
+// RoundTripper 在返回错误时须关闭请求体
+func closeRequestBody(req *http.Request) {
+	if nil != req.Body {
+		_ = req.Body.Close()
+	}
+}
@@ -0,0 +1,171 @@
+package eureka
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+	"go.uber.org/atomic"
+)
+
+// 返回固定状态码并统计请求数的后端
+type testBackend struct {
+	*httptest.Server
+	requests atomic.Int64
+}
+
+func newTestBackend(t *testing.T, status int, delay time.Duration) *testBackend {
+	backend := &testBackend{}
+	backend.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backend.requests.Inc()
+		if 0 < delay {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func newPolicyTestClient(t *testing.T, policy config.ClientPolicy, instances ...core.Instance) *Client {
+	stub := newEurekaStub(t, testApp("DEMO", instances...))
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.Clients = map[string]config.ClientPolicy{"demo": policy}
+	})
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func doGet(t *testing.T, client *Client, url string) (int, string) {
+	resp, err := (&http.Client{Transport: client.NewTransport(nil)}).Get(url)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// 可重试的响应码切换到其他实例重试
+func TestTransportRetriesNextServer(t *testing.T) {
+	bad := newTestBackend(t, http.StatusServiceUnavailable, 0)
+	good := newTestBackend(t, http.StatusOK, 0)
+	client := newPolicyTestClient(t, config.ClientPolicy{RetryableStatusCodes: []int{503}},
+		serverInstance(t, "DEMO", "bad", bad.Server), serverInstance(t, "DEMO", "good", good.Server))
+
+	for i := 0; i < 6; i++ {
+		if status, _ := doGet(t, client, "http://demo/ping"); http.StatusOK != status {
+			t.Fatalf("got status %d, want 200", status)
+		}
+	}
+	if 0 == bad.requests.Load() || 6 != good.requests.Load() {
+		t.Fatalf("got %d requests to the failing instance and %d to the healthy one", bad.requests.Load(), good.requests.Load())
+	}
+}
+
+// 同一实例上最多重试 maxAutoRetries 次, 不切换实例时返回最后一次的结果
+func TestTransportRetriesSameServer(t *testing.T) {
+	bad := newTestBackend(t, http.StatusServiceUnavailable, 0)
+	client := newPolicyTestClient(t, config.ClientPolicy{MaxAutoRetries: 2, MaxAutoRetriesNextServer: -1, RetryableStatusCodes: []int{503}},
+		serverInstance(t, "DEMO", "bad", bad.Server))
+
+	if status, _ := doGet(t, client, "http://demo/ping"); http.StatusServiceUnavailable != status {
+		t.Fatalf("got status %d, want 503", status)
+	}
+	if 3 != bad.requests.Load() {
+		t.Fatalf("got %d requests, want 3", bad.requests.Load())
+	}
+}
+
+// 非幂等请求默认不重试
+func TestTransportDoesNotRetryPost(t *testing.T) {
+	bad := newTestBackend(t, http.StatusServiceUnavailable, 0)
+	other := newTestBackend(t, http.StatusServiceUnavailable, 0)
+	client := newPolicyTestClient(t, config.ClientPolicy{RetryableStatusCodes: []int{503}},
+		serverInstance(t, "DEMO", "bad", bad.Server), serverInstance(t, "DEMO", "other", other.Server))
+
+	resp, err := (&http.Client{Transport: client.NewTransport(nil)}).Post("http://demo/ping", "text/plain", strings.NewReader("body"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if total := bad.requests.Load() + other.requests.Load(); 1 != total {
+		t.Fatalf("got %d requests, want 1", total)
+	}
+}
+
+// 超过 readTimeoutMs 的请求失败并切换实例
+func TestTransportReadTimeout(t *testing.T) {
+	slow := newTestBackend(t, http.StatusOK, time.Second)
+	fast := newTestBackend(t, http.StatusOK, 0)
+	client := newPolicyTestClient(t, config.ClientPolicy{ReadTimeoutMs: 50},
+		serverInstance(t, "DEMO", "slow", slow.Server), serverInstance(t, "DEMO", "fast", fast.Server))
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if status, _ := doGet(t, client, "http://demo/ping"); http.StatusOK != status {
+			t.Fatalf("got status %d, want 200", status)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("took %s, read timeout not applied", elapsed)
+	}
+}
+
+// 只选择元数据匹配的实例, 开启同可用区优先时优先选择同可用区的实例
+func TestPolicyMetadataFiltersAndZone(t *testing.T) {
+	instance := func(id string, version string, zone string) core.Instance {
+		i := upInstance("DEMO", id, "10.0.0.1", 80)
+		i.Metadata = map[string]interface{}{"version": version, metadataZoneKey: zone}
+		return i
+	}
+	client := newPolicyTestClient(t, config.ClientPolicy{MetadataFilters: map[string]string{"version": "2"}, PreferSameZone: true},
+		instance("v1-a", "1", "a"), instance("v2-a", "2", "a"), instance("v2-b", "2", "b"))
+	client.updateInstance(func(instance *core.Instance) {
+		instance.Metadata = map[string]interface{}{metadataZoneKey: "b"}
+	})
+
+	for i := 0; i < 6; i++ {
+		picked, err := client.GetNextServerFromEureka("DEMO")
+		if nil != err || "v2-b" != picked.InstanceId {
+			t.Fatalf("got %s, %v, want v2-b", instanceIdOf(picked), err)
+		}
+	}
+
+	//同可用区没有实例时选择其他可用区
+	client.updateInstance(func(instance *core.Instance) {
+		instance.Metadata = map[string]interface{}{metadataZoneKey: "c"}
+	})
+	seen := make(map[string]bool)
+	for i := 0; i < 6; i++ {
+		picked, _ := client.GetNextServerFromEureka("DEMO")
+		seen[picked.InstanceId] = true
+	}
+	if seen["v1-a"] || !seen["v2-a"] || !seen["v2-b"] {
+		t.Fatalf("got %v, want v2-a and v2-b", seen)
+	}
+}
+
+// 应用可以使用不同于全局配置的负载均衡策略, 应用名忽略大小写
+func TestPolicyOverridesLoadBalancer(t *testing.T) {
+	client := newPolicyTestClient(t, config.ClientPolicy{LoadBalancer: config.LoadBalancerPeakEwma},
+		upInstance("DEMO", "demo-1", "10.0.0.1", 80))
+	if _, ok := client.policy("demo").balancer.(*peakEwmaBalancer); !ok {
+		t.Fatalf("got %T, want peakEwma", client.policy("demo").balancer)
+	}
+	if _, ok := client.policy("OTHER").balancer.(*roundRobinBalancer); !ok {
+		t.Fatalf("got %T for unconfigured app, want roundRobin", client.policy("OTHER").balancer)
+	}
+}
@@ -0,0 +1,73 @@
+package eureka
+
+import (
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// 实例的预热权重, 范围 (0, 1], 1 表示已预热完成
+// 上线时间优先取 LeaseInfo.ServiceUpTimestamp, 否则取首次在注册表刷新中发现的时间
+func (client *Client) warmupWeight(instance *core.Instance, now time.Time) float64 {
+	cfg := &client.config.Load().ClientConfig.Warmup
+	if 0 >= cfg.Seconds {
+		return 1
+	}
+
+	var upTime time.Time
+	if nil != instance.LeaseInfo && 0 < instance.LeaseInfo.ServiceUpTimestamp {
+		upTime = time.UnixMilli(instance.LeaseInfo.ServiceUpTimestamp)
+	} else if stats := client.outliers.get(instance.InstanceId); nil != stats {
+		upTime = stats.firstSeen
+	}
+	if upTime.IsZero() {
+		return 1
+	}
+
+	progress := float64(now.Sub(upTime)) / float64(time.Duration(cfg.Seconds)*time.Second)
+	if 1 <= progress {
+		return 1
+	}
+	if 0 > progress {
+		progress = 0
+	}
+
+	minWeight := float64(cfg.GetMinWeightPercent()) / 100
+	if config.WarmupCurveExponential == cfg.GetCurve() {
+		//从 minWeight 指数增长到 1
+		return math.Pow(minWeight, 1-progress)
+	}
+	return minWeight + (1-minWeight)*progress
+}
+
+// 存在预热中的实例时按预热权重随机选择, 全部已预热完成时返回nil
+func (client *Client) chooseWarming(candidates []*server) *server {
+	if 0 >= client.config.Load().ClientConfig.Warmup.Seconds {
+		return nil
+	}
+
+	now := time.Now()
+	warming := false
+	total := 0.0
+	for _, s := range candidates {
+		weight := client.warmupWeight(s.instance, now)
+		if 1 > weight {
+			warming = true
+		}
+		total += weight
+	}
+	if !warming {
+		return nil
+	}
+
+	r := rand.Float64() * total
+	for _, s := range candidates {
+		r -= client.warmupWeight(s.instance, now)
+		if 0 > r {
+			return s
+		}
+	}
+	return candidates[len(candidates)-1]
+}
@@ -0,0 +1,90 @@
+package eureka
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/phpdragon/go-eureka-client/config"
+	"github.com/phpdragon/go-eureka-client/core"
+)
+
+func newWarmupClient(t *testing.T, warmup config.WarmupConfig, apps ...core.Application) *Client {
+	stub := newEurekaStub(t, apps...)
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.ClientConfig.Warmup = warmup
+	})
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func startedAgo(instance core.Instance, ago time.Duration) core.Instance {
+	instance.LeaseInfo = &core.LeaseInfo{ServiceUpTimestamp: time.Now().Add(-ago).UnixMilli()}
+	return instance
+}
+
+// 预热权重按曲线从 minWeightPercent 增长到 1
+func TestWarmupWeightCurves(t *testing.T) {
+	instance := startedAgo(upInstance("DEMO", "demo-1", "10.0.0.1", 80), 50*time.Second)
+	now := time.Now()
+
+	client := newWarmupClient(t, config.WarmupConfig{Seconds: 100, MinWeightPercent: 10})
+	if got := client.warmupWeight(&instance, now); 0.01 < math.Abs(0.55-got) {
+		t.Fatalf("linear: got %v at half way, want 0.55", got)
+	}
+
+	client = newWarmupClient(t, config.WarmupConfig{Seconds: 100, MinWeightPercent: 10, Curve: config.WarmupCurveExponential})
+	if got := client.warmupWeight(&instance, now); 0.01 < math.Abs(math.Sqrt(0.1)-got) {
+		t.Fatalf("exponential: got %v at half way, want %v", got, math.Sqrt(0.1))
+	}
+
+	warm := startedAgo(instance, 200*time.Second)
+	if got := client.warmupWeight(&warm, now); 1 != got {
+		t.Fatalf("got %v after warmup, want 1", got)
+	}
+}
+
+// 启动时已存在的实例视为已预热, 之后新发现的实例从首次发现时开始预热
+func TestWarmupUsesFirstSeen(t *testing.T) {
+	stub := newEurekaStub(t, testApp("DEMO", upInstance("DEMO", "demo-1", "10.0.0.1", 80)))
+	client := newTestClient(t, stub, func(cfg *config.Config) {
+		cfg.ClientConfig.Warmup = config.WarmupConfig{Seconds: 100}
+	})
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+	stub.setApps(testApp("DEMO", upInstance("DEMO", "demo-1", "10.0.0.1", 80), upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+	if err := client.fetchRegistry(); nil != err {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if got := client.warmupWeight(registryInstance(client, "demo-1"), now); 1 != got {
+		t.Fatalf("existing instance: got %v, want 1", got)
+	}
+	if got := client.warmupWeight(registryInstance(client, "demo-2"), now); 0.2 < got {
+		t.Fatalf("new instance: got %v, want about 0.1", got)
+	}
+}
+
+// 预热中的实例被选中的比例按权重降低
+func TestWarmupReducesTraffic(t *testing.T) {
+	client := newWarmupClient(t, config.WarmupConfig{Seconds: 1000, MinWeightPercent: 10}, testApp("DEMO",
+		startedAgo(upInstance("DEMO", "demo-1", "10.0.0.1", 80), 0),
+		upInstance("DEMO", "demo-2", "10.0.0.2", 80)))
+
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		instance, err := client.GetNextServerFromEureka("DEMO")
+		if nil != err {
+			t.Fatal(err)
+		}
+		counts[instance.InstanceId]++
+	}
+	//期望约 1/11
+	if counts["demo-1"] > 400 || 0 == counts["demo-1"] {
+		t.Fatalf("got %v, want demo-1 to receive about 9%%", counts)
+	}
+}